@@ -0,0 +1,280 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errBatcherClosed = errors.New("log exporter is shut down")
+
+type batcherOptions struct {
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxQueueSize  int
+	// OnDrop is called when items are dropped because the queue is full or because they could not be sent
+	OnDrop func(err error, count int)
+}
+
+// batcher queues items and sends them by batches from a background goroutine,
+// when a batch is full or every flush interval.
+type batcher[T any] struct {
+	opts   batcherOptions
+	send   func(ctx context.Context, items []T) error
+	mutex  sync.Mutex
+	items  []T
+	closed bool
+	// sending is a semaphore of one slot serializing the sends, acquired with respect to the context of Flush
+	sending chan struct{}
+	dropped atomic.Uint64
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	// ctx is the context of the background flushes, cancel aborts them when Shutdown gives up
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newBatcher[T any](opts batcherOptions, send func(ctx context.Context, items []T) error) *batcher[T] {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxQueueSize < opts.BatchSize {
+		opts.MaxQueueSize = 4 * opts.BatchSize
+	}
+	b := &batcher[T]{
+		opts:    opts,
+		send:    send,
+		sending: make(chan struct{}, 1),
+		flushCh: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return b
+}
+
+func (b *batcher[T]) Add(item T) {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		b.drop(errBatcherClosed, 1)
+		return
+	}
+	if len(b.items) >= b.opts.MaxQueueSize {
+		b.mutex.Unlock()
+		b.drop(errors.New("log queue is full"), 1)
+		return
+	}
+	b.items = append(b.items, item)
+	full := len(b.items) >= b.opts.BatchSize
+	b.mutex.Unlock()
+
+	if full {
+		select {
+		case b.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *batcher[T]) run() {
+	defer close(b.doneCh)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+		case <-b.flushCh:
+		}
+		_ = b.Flush(b.ctx)
+	}
+}
+
+// Flush sends all the queued items. It returns the last error met, the items that could not be sent are dropped.
+// It returns the error of ctx if ctx is done while another flush is sending.
+func (b *batcher[T]) Flush(ctx context.Context) error {
+	select {
+	case b.sending <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-b.sending }()
+
+	var lastErr error
+	for {
+		b.mutex.Lock()
+		n := min(len(b.items), b.opts.BatchSize)
+		batch := make([]T, n)
+		copy(batch, b.items)
+		b.items = b.items[n:]
+		if len(b.items) == 0 {
+			b.items = nil
+		}
+		b.mutex.Unlock()
+
+		if n == 0 {
+			return lastErr
+		}
+		if err := b.send(ctx, batch); err != nil {
+			lastErr = err
+			count := n
+			var partial *partialSendError
+			if errors.As(err, &partial) {
+				count = partial.count
+			}
+			b.drop(err, count)
+		}
+	}
+}
+
+// Shutdown stops the background goroutine and sends the remaining items. Items added afterwards are dropped.
+// If ctx is done first, the background flush is aborted.
+func (b *batcher[T]) Shutdown(ctx context.Context) error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mutex.Unlock()
+
+	defer b.cancel()
+	close(b.stopCh)
+	select {
+	case <-b.doneCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.Flush(ctx)
+}
+
+func (b *batcher[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+func (b *batcher[T]) drop(err error, count int) {
+	b.dropped.Add(uint64(count))
+	if b.opts.OnDrop != nil {
+		b.opts.OnDrop(err, count)
+	}
+}
+
+// partialSendError is returned by the send function of a batcher when only count items of the batch could not be sent.
+type partialSendError struct {
+	err   error
+	count int
+}
+
+func (e *partialSendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialSendError) Unwrap() error {
+	return e.err
+}
+
+type retryOptions struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (o retryOptions) withDefaults() retryOptions {
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 500 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	return o
+}
+
+// retryableError marks an error as temporary. retryAfter overrides the backoff when it is set.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// retry calls fn until it succeeds, returns an error that is not a *retryableError or the retries are exhausted,
+// doubling the backoff between each attempt.
+func retry(ctx context.Context, opts retryOptions, fn func() error) error {
+	opts = opts.withDefaults()
+	backoff := opts.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= opts.MaxRetries {
+			return err
+		}
+
+		wait := backoff
+		if retryable.retryAfter > 0 {
+			wait = min(retryable.retryAfter, opts.MaxBackoff)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, opts.MaxBackoff)
+	}
+}
+
+// postWithRetry posts body to url, retrying on network errors and on the statuses for which retryStatus returns true.
+func postWithRetry(ctx context.Context, client *http.Client, url string, header http.Header, body []byte, opts retryOptions, retryStatus func(status int) bool) error {
+	return retry(ctx, opts, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return &retryableError{err: err}
+		}
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+
+		err = fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+		if !retryStatus(resp.StatusCode) {
+			return err
+		}
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &retryableError{err: err, retryAfter: time.Duration(retryAfter) * time.Second}
+	})
+}
@@ -0,0 +1,136 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const contextKeyBuffer = contextKey("buffer")
+
+type BufferOptions struct {
+	// Threshold defaults to LevelInfo, the entries below it are held in the buffer
+	Threshold Level
+	// FlushLevel defaults to LevelError, an entry at or above it flushes the buffer before being written
+	FlushLevel Level
+	// MaxEntries defaults to 1000 and MaxBytes to 1MiB. The oldest entries are dropped when the buffer is full.
+	MaxEntries int
+	MaxBytes   int
+}
+
+// WithBuffer returns a context holding the debug entries logged with it in memory.
+// They are written only if an error is logged with the same context, before the error itself.
+// The returned function discards the entries still held and must be called when the scope ends.
+func WithBuffer(ctx context.Context) (context.Context, func()) {
+	return WithBufferOptions(ctx, BufferOptions{})
+}
+
+// WithBufferOptions is WithBuffer with options.
+// The levels of the loggers are not checked for the held entries: to have the backends receive the debug entries
+// when the buffer is flushed, let them accept the debug level and use Logger.SetLevel to filter the entries instead.
+func WithBufferOptions(ctx context.Context, opts BufferOptions) (context.Context, func()) {
+	if opts.Threshold == LevelDebug {
+		opts.Threshold = LevelInfo
+	}
+	if opts.FlushLevel == LevelDebug {
+		opts.FlushLevel = LevelError
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 1 << 20
+	}
+	b := &logBuffer{opts: opts}
+	return context.WithValue(ctx, contextKeyBuffer, b), b.close
+}
+
+type bufferedEntry struct {
+	factory WrapperFactoryFunc
+	entry   entry
+	size    int
+}
+
+type logBuffer struct {
+	opts    BufferOptions
+	mutex   sync.Mutex
+	entries []bufferedEntry
+	size    int
+	dropped int
+	closed  bool
+}
+
+func bufferFromContext(ctx context.Context) *logBuffer {
+	b, _ := ctx.Value(contextKeyBuffer).(*logBuffer)
+	return b
+}
+
+func (b *logBuffer) holds(level Level) bool {
+	if b == nil {
+		return false
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return !b.closed && level < b.opts.Threshold
+}
+
+func (b *logBuffer) triggers(level Level) bool {
+	return b != nil && level >= b.opts.FlushLevel
+}
+
+func (b *logBuffer) add(factory WrapperFactoryFunc, e entry) {
+	// The message is formatted now, the arguments may change before the buffer is flushed
+	if e.time.IsZero() {
+		e.time = time.Now()
+	}
+	e.format = getFormatedMsg(e.format, e.args...)
+	e.args = nil
+
+	size := len(e.format)
+	for _, f := range e.fields {
+		size += len(f.key)
+		if s, ok := f.value.(string); ok {
+			size += len(s)
+		} else {
+			size += 16
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.entries = append(b.entries, bufferedEntry{factory: factory, entry: e, size: size})
+	b.size += size
+	for len(b.entries) > 0 && (len(b.entries) > b.opts.MaxEntries || b.size > b.opts.MaxBytes) {
+		b.size -= b.entries[0].size
+		b.entries = b.entries[1:]
+		b.dropped++
+	}
+}
+
+// flush writes the held entries in order, with their original time when the wrapper supports it.
+func (b *logBuffer) flush(factory WrapperFactoryFunc) {
+	b.mutex.Lock()
+	entries, dropped := b.entries, b.dropped
+	b.entries, b.size, b.dropped = nil, 0, 0
+	b.mutex.Unlock()
+
+	if dropped > 0 {
+		entry{level: LevelWarn, format: "%d buffered log entries dropped because the buffer was full", args: []interface{}{dropped}}.writeTo(factory())
+	}
+	for _, be := range entries {
+		be.entry.writeTo(be.factory())
+	}
+}
+
+func (b *logBuffer) close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	b.entries, b.size, b.dropped = nil, 0, 0
+}
@@ -0,0 +1,123 @@
+package log_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+type timeWrapper struct {
+	recordingWrapper
+	times *[]time.Time
+}
+
+func (w *timeWrapper) WithTime(t time.Time) {
+	*w.times = append(*w.times, t)
+}
+
+func ExampleWithBuffer() {
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{DisableTimestamp: true}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.SetLevel(log.LevelInfo)
+
+	ctx, done := log.WithBuffer(context.Background())
+	defer done()
+
+	logger.Debug(ctx, "loading %s", "foo")
+	logger.Info(ctx, "this is info")
+	logger.Error(ctx, "unable to load %s", "foo")
+	// Output:
+	// [INFO]  this is info
+	// [DEBUG]  loading foo
+	// [ERROR]  unable to load foo
+}
+
+func newBufferTestLogger() (*log.Logger, *[]string, *[]time.Time) {
+	var mutex sync.Mutex
+	var lines []string
+	var times []time.Time
+	logger := log.NewWithFactory(func() log.Wrapper {
+		return &timeWrapper{recordingWrapper: recordingWrapper{mutex: &mutex, lines: &lines}, times: &times}
+	})
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.SetLevel(log.LevelInfo)
+	return logger, &lines, &times
+}
+
+func TestWithBufferFlush(t *testing.T) {
+	logger, lines, times := newBufferTestLogger()
+	ctx, done := log.WithBuffer(context.Background())
+	defer done()
+
+	before := time.Now()
+	logger.Debug(ctx, "first")
+	logger.Debug(ctx, "second")
+	if len(*lines) != 0 {
+		t.Fatalf("debug entries should be held, got %v", *lines)
+	}
+	time.Sleep(10 * time.Millisecond)
+	logger.Error(ctx, "failure")
+
+	want := []string{"[DEBUG] first", "[DEBUG] second", "[ERROR] failure"}
+	if len(*lines) != len(want) {
+		t.Fatalf("want %v, got %v", want, *lines)
+	}
+	for i := range want {
+		if (*lines)[i] != want[i] {
+			t.Fatalf("want %v, got %v", want, *lines)
+		}
+	}
+	if len(*times) != 2 {
+		t.Fatalf("want the original time of the 2 held entries, got %v", *times)
+	}
+	for _, ts := range *times {
+		if ts.Before(before) || time.Since(ts) < 10*time.Millisecond {
+			t.Fatalf("unexpected entry time %v", ts)
+		}
+	}
+
+	// The buffer keeps holding entries after a flush
+	logger.Debug(ctx, "third")
+	if len(*lines) != 3 {
+		t.Fatalf("debug entry should be held, got %v", *lines)
+	}
+}
+
+func TestWithBufferDiscard(t *testing.T) {
+	logger, lines, _ := newBufferTestLogger()
+	ctx, done := log.WithBuffer(context.Background())
+	logger.Debug(ctx, "first")
+	logger.Warn(ctx, "warning")
+	done()
+	logger.Error(ctx, "failure")
+	logger.Debug(ctx, "filtered by the logger level")
+
+	want := []string{"[WARN] warning", "[ERROR] failure"}
+	if len(*lines) != len(want) || (*lines)[0] != want[0] || (*lines)[1] != want[1] {
+		t.Fatalf("want %v, got %v", want, *lines)
+	}
+}
+
+func TestWithBufferLimits(t *testing.T) {
+	logger, lines, _ := newBufferTestLogger()
+	ctx, done := log.WithBufferOptions(context.Background(), log.BufferOptions{MaxEntries: 2})
+	defer done()
+
+	logger.Debug(ctx, "first")
+	logger.Debug(ctx, "second")
+	logger.Debug(ctx, "third")
+	logger.Error(ctx, "failure")
+
+	want := []string{"[WARN] 1 buffered log entries dropped because the buffer was full", "[DEBUG] second", "[DEBUG] third", "[ERROR] failure"}
+	if len(*lines) != len(want) {
+		t.Fatalf("want %v, got %v", want, *lines)
+	}
+	for i := range want {
+		if (*lines)[i] != want[i] {
+			t.Fatalf("want %v, got %v", want, *lines)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type PathFormat int
+
+const (
+	PathFull           PathFormat = iota // /home/me/src/mymodule/pkg/file.go
+	PathModuleRelative                   // pkg/file.go
+	PathBase                             // file.go
+)
+
+type FunctionFormat int
+
+const (
+	FunctionFull    FunctionFormat = iota // github.com/me/mymodule/pkg.(*T).Method
+	FunctionPackage                       // pkg.(*T).Method
+	FunctionShort                         // (*T).Method
+)
+
+type CallerOptions struct {
+	PathFormat     PathFormat
+	FunctionFormat FunctionFormat
+	// Structured emits a single Caller value as FieldCaller instead of FieldSourceFile, FieldSourceLine and FieldCaller.
+	Structured bool
+}
+
+type Caller struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+func (c Caller) String() string {
+	return fmt.Sprintf("%s (%s:%d)", c.Function, c.File, c.Line)
+}
+
+func (c Caller) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", c.Function)
+	enc.AddString("file", c.File)
+	enc.AddInt("line", c.Line)
+	return nil
+}
+
+func (l *Logger) GetCallerOptions() CallerOptions {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.callerOptions
+}
+
+func (l *Logger) SetCallerOptions(opts CallerOptions) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.callerOptions = opts
+}
+
+var packagePath = functionPackage(runtime.FuncForPC(reflect.ValueOf(New).Pointer()).Name())
+
+// Helper marks the calling function as a logging helper. Its frames are skipped when resolving the caller.
+func (l *Logger) Helper() {
+	var pcs [8]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if functionPackage(frame.Function) != packagePath {
+			l.helpers.Store(frame.Function, struct{}{})
+			return
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// RegisterHelperPackage marks every function of the package prefix and of the packages below it, such as prefix/sub,
+// as a logging helper.
+func (l *Logger) RegisterHelperPackage(prefix string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, p := range l.helperPrefixes {
+		if p == prefix {
+			return
+		}
+	}
+	l.helperPrefixes = append(l.helperPrefixes, prefix)
+}
+
+func (l *Logger) isHelper(function string, helperPrefixes []string) bool {
+	if _, ok := l.helpers.Load(function); ok {
+		return true
+	}
+	pkg := functionPackage(function)
+	if pkg == packagePath || pkg == "runtime" {
+		return true
+	}
+	for _, prefix := range helperPrefixes {
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Logger) copyHelperPrefixes() []string {
+	helperPrefixes := make([]string, len(l.helperPrefixes))
+	copy(helperPrefixes, l.helperPrefixes)
+	return helperPrefixes
+}
+
+// callers starts from the frame skip levels above the caller of callers, the same way runtime.Caller(skip) would.
+// It then skips the frames of this package, of the runtime and of logging helpers, plus depth more frames.
+func (l *Logger) callers(skip, depth int, helperPrefixes []string) []uintptr {
+	pcs := make([]uintptr, 64)
+	pcs = pcs[:runtime.Callers(skip+2, pcs)]
+	for len(pcs) > 0 {
+		frame, _ := runtime.CallersFrames(pcs[:1]).Next()
+		if !l.isHelper(frame.Function, helperPrefixes) {
+			if depth <= 0 {
+				return pcs
+			}
+			depth--
+		}
+		pcs = pcs[1:]
+	}
+	return nil
+}
+
+// Contrary to runtime.FuncForPC, runtime.CallersFrames reports inlined functions correctly.
+func (l *Logger) callerFrame(skip, depth int, helperPrefixes []string) (runtime.Frame, bool) {
+	pcs := l.callers(skip+1, depth, helperPrefixes)
+	if len(pcs) == 0 {
+		return runtime.Frame{}, false
+	}
+	frame, _ := runtime.CallersFrames(pcs[:1]).Next()
+	return frame, true
+}
+
+// functionPackage returns the import path of the package of a function name as reported by runtime.Frame.
+func functionPackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+func newCaller(frame runtime.Frame, opts CallerOptions) Caller {
+	return Caller{
+		Function: FormatFunction(frame.Function, opts.FunctionFormat),
+		File:     FormatPath(frame.File, opts.PathFormat),
+		Line:     frame.Line,
+	}
+}
+
+func FormatFunction(function string, format FunctionFormat) string {
+	switch format {
+	case FunctionPackage:
+		return function[strings.LastIndex(function, "/")+1:]
+	case FunctionShort:
+		function = function[strings.LastIndex(function, "/")+1:]
+		return function[strings.Index(function, ".")+1:]
+	default:
+		return function
+	}
+}
+
+func FormatPath(path string, format PathFormat) string {
+	switch format {
+	case PathModuleRelative:
+		return ModuleRelativePath(path)
+	case PathBase:
+		return filepath.Base(path)
+	default:
+		return path
+	}
+}
+
+var moduleRoots sync.Map // directory -> module root directory, empty if none
+
+// ModuleRelativePath returns path relative to the root of the Go module containing it, found by looking for a go.mod file.
+// When no module can be found, the last directory and the file name are kept.
+func ModuleRelativePath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	dir := filepath.Dir(path)
+	if root := moduleRoot(dir); root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(filepath.Join(filepath.Base(dir), filepath.Base(path)))
+}
+
+func moduleRoot(dir string) string {
+	if root, ok := moduleRoots.Load(dir); ok {
+		return root.(string)
+	}
+	var root string
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = moduleRoot(parent)
+	}
+	moduleRoots.Store(dir, root)
+	return root
+}
+
+func GetCallerOptions() CallerOptions {
+	return global.GetCallerOptions()
+}
+
+func SetCallerOptions(opts CallerOptions) {
+	global.SetCallerOptions(opts)
+}
+
+func Helper() {
+	global.Helper()
+}
+
+func RegisterHelperPackage(prefix string) {
+	global.RegisterHelperPackage(prefix)
+}
@@ -0,0 +1,136 @@
+package log_test
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rockbears/log"
+)
+
+func ExampleLogger_SetCallerOptions() {
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{DisableTimestamp: true}))
+	logger.UnregisterField(log.FieldSourceLine)
+
+	logger.SetCallerOptions(log.CallerOptions{PathFormat: log.PathModuleRelative, FunctionFormat: log.FunctionShort})
+	logger.Info(context.Background(), "this is info")
+
+	logger.SetCallerOptions(log.CallerOptions{PathFormat: log.PathBase, FunctionFormat: log.FunctionPackage, Structured: true})
+	logger.Info(context.Background(), "this is structured")
+	// Output:
+	// [INFO] [caller=ExampleLogger_SetCallerOptions][source_file=caller_test.go] this is info
+	// [INFO] [caller=log_test.ExampleLogger_SetCallerOptions (caller_test.go:20)] this is structured
+}
+
+type callerWrapper struct {
+	fields map[string]interface{}
+}
+
+func (w *callerWrapper) GetLevel() log.Level                       { return log.LevelDebug }
+func (w *callerWrapper) WithField(key string, value interface{})   { w.fields[key] = value }
+func (w *callerWrapper) Debugf(format string, args ...interface{}) {}
+func (w *callerWrapper) Infof(format string, args ...interface{})  {}
+func (w *callerWrapper) Warnf(format string, args ...interface{})  {}
+func (w *callerWrapper) Fatalf(format string, args ...interface{}) {}
+func (w *callerWrapper) Errorf(format string, args ...interface{}) {}
+func (w *callerWrapper) Panicf(format string, args ...interface{}) {}
+
+func inlinedHelper(logger *log.Logger) {
+	logger.Info(context.Background(), "from helper")
+}
+
+func TestCallerInlined(t *testing.T) {
+	w := &callerWrapper{fields: map[string]interface{}{}}
+	logger := log.NewWithFactory(func() log.Wrapper { return w })
+
+	inlinedHelper(logger)
+
+	if got, want := w.fields[string(log.FieldCaller)], "github.com/rockbears/log_test.inlinedHelper"; got != want {
+		t.Fatalf("want caller %q, got %q", want, got)
+	}
+	if got, want := w.fields[string(log.FieldSourceLine)], 40; got != want {
+		t.Fatalf("want line %d, got %v", want, got)
+	}
+}
+
+func TestModuleRelativePath(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	if got, want := log.ModuleRelativePath(file), "caller_test.go"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if got, want := log.ModuleRelativePath("relative/path.go"), "relative/path.go"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	outside := filepath.Join(string(filepath.Separator), "nowhere", "pkg", "file.go")
+	if got, want := log.ModuleRelativePath(outside), "pkg/file.go"; got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestFormatFunction(t *testing.T) {
+	const fn = "github.com/me/my.module/pkg.(*T).Method.func1"
+	for format, want := range map[log.FunctionFormat]string{
+		log.FunctionFull:    fn,
+		log.FunctionPackage: "pkg.(*T).Method.func1",
+		log.FunctionShort:   "(*T).Method.func1",
+	} {
+		if got := log.FormatFunction(fn, format); got != want {
+			t.Errorf("format %d: want %q, got %q", format, want, got)
+		}
+	}
+}
+
+func markedHelper(logger *log.Logger) {
+	logger.Helper()
+	logger.Info(context.Background(), "from marked helper")
+}
+
+func depthHelper(logger *log.Logger) {
+	logger.InfoDepth(context.Background(), 1, "from depth helper")
+}
+
+func globalHelper() {
+	log.Helper()
+	log.Warn(context.Background(), "from global helper")
+}
+
+func TestHelper(t *testing.T) {
+	w := &callerWrapper{fields: map[string]interface{}{}}
+	logger := log.NewWithFactory(func() log.Wrapper { return w })
+	const want = "github.com/rockbears/log_test.TestHelper"
+
+	markedHelper(logger)
+	if got := w.fields[string(log.FieldCaller)]; got != want {
+		t.Fatalf("marked helper: want caller %q, got %q", want, got)
+	}
+
+	depthHelper(logger)
+	if got := w.fields[string(log.FieldCaller)]; got != want {
+		t.Fatalf("depth helper: want caller %q, got %q", want, got)
+	}
+
+	logger.RegisterHelperPackage("github.com/rockbears/log_test")
+	inlinedHelper(logger)
+	if got := w.fields[string(log.FieldCaller)]; got != "testing.tRunner" {
+		t.Fatalf("helper package: want caller %q, got %q", "testing.tRunner", got)
+	}
+
+	defer func(factory log.WrapperFactoryFunc) { log.Factory = factory }(log.Factory)
+	log.Factory = func() log.Wrapper { return w }
+	globalHelper()
+	if got := w.fields[string(log.FieldCaller)]; got != want {
+		t.Fatalf("global helper: want caller %q, got %q", want, got)
+	}
+}
+
+func TestHelperPackagePrefix(t *testing.T) {
+	w := &callerWrapper{fields: map[string]interface{}{}}
+	logger := log.NewWithFactory(func() log.Wrapper { return w })
+	// github.com/rockbears/log_test only shares the prefix, it is not a helper package
+	logger.RegisterHelperPackage("github.com/rockbears/log_te")
+	inlinedHelper(logger)
+	if got, want := w.fields[string(log.FieldCaller)], "github.com/rockbears/log_test.inlinedHelper"; got != want {
+		t.Fatalf("want caller %q, got %q", want, got)
+	}
+}
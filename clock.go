@@ -0,0 +1,63 @@
+package log
+
+import (
+	"sort"
+	"time"
+)
+
+// deterministicTime is the time of all the entries in deterministic mode, unless a clock is set.
+var deterministicTime = time.Unix(0, 0).UTC()
+
+// SetClock sets the function giving the time of the entries, which is passed to the wrappers implementing TimeWrapper.
+// The wrappers use the current time when no clock is set.
+func (l *Logger) SetClock(clock func() time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.clock = clock
+}
+
+// SetDeterministic makes the output of the logger reproducible, for Example tests or golden files:
+// the time of the entries is frozen at the Unix epoch unless a clock is set, full source paths are module-relative
+// for the caller and the structured stack traces, and the fields are given to the wrappers sorted by name.
+func (l *Logger) SetDeterministic(deterministic bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.deterministic = deterministic
+}
+
+// entryTime returns the zero time when the wrappers should use the current time.
+func entryTime(clock func() time.Time, deterministic bool) time.Time {
+	switch {
+	case clock != nil:
+		return clock()
+	case deterministic:
+		return deterministicTime
+	default:
+		return time.Time{}
+	}
+}
+
+// effectiveCallerOptions must be called with the mutex held.
+func (l *Logger) effectiveCallerOptions() CallerOptions {
+	opts := l.callerOptions
+	if l.deterministic && opts.PathFormat == PathFull {
+		opts.PathFormat = PathModuleRelative
+	}
+	return opts
+}
+
+func sortEntryFields(fields []entryField) {
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].key < fields[j].key
+	})
+}
+
+func SetClock(clock func() time.Time) {
+	global.SetClock(clock)
+}
+
+func SetDeterministic(deterministic bool) {
+	global.SetDeterministic(deterministic)
+}
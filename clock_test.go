@@ -0,0 +1,84 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	stdlog "log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+	"github.com/rockbears/log/logtest"
+	"github.com/sirupsen/logrus"
+)
+
+func ExampleLogger_SetDeterministic() {
+	logrusLogger := logrus.New()
+	logrusLogger.SetOutput(os.Stdout)
+	logrusLogger.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+	logger := log.NewWithFactory(log.NewLogrusWrapper(logrusLogger))
+	logger.SetDeterministic(true)
+	logger.RegisterField(fieldComponent, fieldAsset)
+
+	ctx := context.WithValue(context.Background(), fieldComponent, "rockbears/log")
+	ctx = context.WithValue(ctx, fieldAsset, "ExampleLogger_SetDeterministic")
+	logger.Info(ctx, "this is info")
+	// Output:
+	// time="1970-01-01T00:00:00Z" level=info msg="this is info" asset=ExampleLogger_SetDeterministic caller=github.com/rockbears/log_test.ExampleLogger_SetDeterministic component=rockbears/log source_file=clock_test.go source_line=26
+}
+
+func TestSetClock(t *testing.T) {
+	rec := logtest.NewRecorder()
+	logger := log.NewWithFactory(rec.Factory())
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	logger.SetClock(func() time.Time { return now })
+
+	ctx, done := log.WithBuffer(context.Background())
+	defer done()
+	logger.Debug(ctx, "held")
+	now = now.Add(time.Second)
+	logger.Error(ctx, "failure")
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("want 2 entries, got %v", entries)
+	}
+	if !entries[0].Time.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) || !entries[1].Time.Equal(now) {
+		t.Fatalf("unexpected times %v and %v", entries[0].Time, entries[1].Time)
+	}
+}
+
+func TestStdWrapperTimeFlags(t *testing.T) {
+	var buf bytes.Buffer
+	output, flags, prefix := stdlog.Writer(), stdlog.Flags(), stdlog.Prefix()
+	defer func() {
+		stdlog.SetOutput(output)
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+	}()
+	stdlog.SetOutput(&buf)
+
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.SetClock(func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 1000, time.FixedZone("CEST", 2*3600)) })
+
+	for _, tc := range []struct {
+		flags  int
+		prefix string
+		want   string
+	}{
+		{stdlog.LstdFlags, "", "2024/05/01 12:00:00 [INFO]  this is info\n"},
+		{stdlog.Ltime | stdlog.Lmicroseconds | stdlog.LUTC, "app: ", "app: 10:00:00.000001 [INFO]  this is info\n"},
+		{stdlog.Ldate | stdlog.Lmsgprefix, "app: ", "2024/05/01 app: [INFO]  this is info\n"},
+		{0, "", "[INFO]  this is info\n"},
+	} {
+		buf.Reset()
+		stdlog.SetFlags(tc.flags)
+		stdlog.SetPrefix(tc.prefix)
+		logger.Info(context.Background(), "this is info")
+		if buf.String() != tc.want {
+			t.Errorf("flags %d: want %q, got %q", tc.flags, tc.want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config describes the runtime configuration of a Logger. Nil members are left untouched when the config is applied.
+// Fields are registered in addition to the default fields and to the ones registered in code, which a config never removes.
+// Likewise, the exclude rules replace the ones of the previous config but apply in addition to the ones set with Skip.
+type Config struct {
+	Level   *Level        `json:"level,omitempty"`
+	Fields  []Field       `json:"fields,omitempty"`
+	Exclude []ExcludeRule `json:"exclude,omitempty"`
+}
+
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("unable to parse log config: %v", err)
+	}
+	for i, rule := range cfg.Exclude {
+		if rule.Field == "" {
+			return cfg, fmt.Errorf("invalid log config: exclude rule without field")
+		}
+		// Integers are kept as int64 rather than float64, they are compared by value with the context values
+		if n, ok := rule.Value.(json.Number); ok {
+			if i64, err := n.Int64(); err == nil {
+				cfg.Exclude[i].Value = i64
+			} else if f64, err := n.Float64(); err == nil {
+				cfg.Exclude[i].Value = f64
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func LoadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// ApplyConfig atomically applies the config and returns a description of each change.
+func (l *Logger) ApplyConfig(cfg Config) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var changes []string
+
+	if cfg.Level != nil && *cfg.Level != l.level {
+		changes = append(changes, fmt.Sprintf("level %s -> %s", l.level, *cfg.Level))
+		l.level = *cfg.Level
+	}
+
+	if cfg.Fields != nil {
+		inConfig := make(map[Field]bool, len(cfg.Fields))
+		for _, f := range cfg.Fields {
+			inConfig[f] = true
+		}
+		fields := make([]Field, 0, len(l.registeredFields)+len(cfg.Fields))
+		for _, f := range l.registeredFields {
+			// The fields added by the previous config are removed unless the new one still has them
+			if inConfig[f] || !containsField(l.configFields, f) {
+				fields = append(fields, f)
+			}
+		}
+		configFields := make([]Field, 0, len(cfg.Fields))
+		for _, f := range l.configFields {
+			if inConfig[f] {
+				configFields = append(configFields, f)
+			}
+		}
+		for _, f := range cfg.Fields {
+			if !containsField(fields, f) {
+				fields = append(fields, f)
+				configFields = append(configFields, f)
+			}
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i] < fields[j] })
+
+		added, removed := diffFields(l.registeredFields, fields)
+		if len(added) > 0 {
+			changes = append(changes, fmt.Sprintf("fields added %v", added))
+		}
+		if len(removed) > 0 {
+			changes = append(changes, fmt.Sprintf("fields removed %v", removed))
+		}
+		l.registeredFields = fields
+		l.configFields = configFields
+	}
+
+	if cfg.Exclude != nil {
+		excludeRules := make([]ExcludeRule, 0, len(cfg.Exclude))
+		for _, rule := range cfg.Exclude {
+			var replaced bool
+			for i := range excludeRules {
+				if excludeRules[i].Field == rule.Field {
+					excludeRules[i].Value = rule.Value
+					replaced = true
+				}
+			}
+			if !replaced {
+				excludeRules = append(excludeRules, rule)
+			}
+		}
+		if formatExcludeRules(excludeRules) != formatExcludeRules(l.configExcludeRules) {
+			changes = append(changes, fmt.Sprintf("exclude rules %s -> %s", formatExcludeRules(l.configExcludeRules), formatExcludeRules(excludeRules)))
+		}
+		l.configExcludeRules = excludeRules
+	}
+
+	return changes
+}
+
+// WatchConfigFile loads the config file then polls its modification time every interval until ctx is done.
+// A config that fails to load leaves the previous configuration in place.
+func (l *Logger) WatchConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	l.ApplyConfig(cfg)
+
+	go l.watchConfigFile(ctx, path, interval, info.ModTime(), info.Size())
+	return nil
+}
+
+func (l *Logger) watchConfigFile(ctx context.Context, path string, interval time.Duration, modTime time.Time, size int64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr string
+	reportErr := func(err error) {
+		if err.Error() != lastErr {
+			lastErr = err.Error()
+			l.Error(ctx, "unable to reload log config from %s: %v", path, err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			reportErr(err)
+			continue
+		}
+		if info.ModTime().Equal(modTime) && info.Size() == size {
+			continue
+		}
+
+		cfg, err := LoadConfigFile(path)
+		if err != nil {
+			reportErr(err)
+			continue
+		}
+		modTime, size, lastErr = info.ModTime(), info.Size(), ""
+
+		// The reload entry is emitted even if the new level would filter it
+		reloadCtx := context.WithValue(ctx, contextKeyIgnoreLevel, true)
+		changes := l.ApplyConfig(cfg)
+		if len(changes) == 0 {
+			l.Info(reloadCtx, "log config reloaded from %s: no change", path)
+		} else {
+			l.Info(reloadCtx, "log config reloaded from %s: %s", path, strings.Join(changes, ", "))
+		}
+	}
+}
+
+func diffFields(before, after []Field) (added, removed []Field) {
+	inBefore := make(map[Field]bool, len(before))
+	for _, f := range before {
+		inBefore[f] = true
+	}
+	inAfter := make(map[Field]bool, len(after))
+	for _, f := range after {
+		inAfter[f] = true
+		if !inBefore[f] {
+			added = append(added, f)
+		}
+	}
+	for _, f := range before {
+		if !inAfter[f] {
+			removed = append(removed, f)
+		}
+	}
+	return added, removed
+}
+
+func containsField(fields []Field, f Field) bool {
+	for _, existing := range fields {
+		if existing == f {
+			return true
+		}
+	}
+	return false
+}
+
+func removeField(fields []Field, f Field) []Field {
+	for i, existing := range fields {
+		if existing == f {
+			return append(fields[:i], fields[i+1:]...)
+		}
+	}
+	return fields
+}
+
+func formatExcludeRules(rules []ExcludeRule) string {
+	s := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		s = append(s, fmt.Sprintf("%s=%v", rule.Field, rule.Value))
+	}
+	sort.Strings(s)
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+func ApplyConfig(cfg Config) []string {
+	return global.ApplyConfig(cfg)
+}
+
+func WatchConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	return global.WatchConfigFile(ctx, path, interval)
+}
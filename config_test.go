@@ -0,0 +1,227 @@
+package log_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+type recordingWrapper struct {
+	mutex *sync.Mutex
+	lines *[]string
+	// fields, if set, receives the fields of each line
+	fields  *[]map[string]interface{}
+	level   log.Level
+	current map[string]interface{}
+}
+
+func (r *recordingWrapper) GetLevel() log.Level { return r.level }
+func (r *recordingWrapper) WithField(key string, value interface{}) {
+	if r.current == nil {
+		r.current = map[string]interface{}{}
+	}
+	r.current[key] = value
+}
+func (r *recordingWrapper) record(level string, format string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	*r.lines = append(*r.lines, "["+level+"] "+fmt.Sprintf(format, args...))
+	if r.fields != nil {
+		*r.fields = append(*r.fields, r.current)
+	}
+}
+func (r *recordingWrapper) Debugf(format string, args ...interface{}) {
+	r.record("DEBUG", format, args...)
+}
+func (r *recordingWrapper) Infof(format string, args ...interface{}) {
+	r.record("INFO", format, args...)
+}
+func (r *recordingWrapper) Warnf(format string, args ...interface{}) {
+	r.record("WARN", format, args...)
+}
+func (r *recordingWrapper) Fatalf(format string, args ...interface{}) {
+	r.record("FATAL", format, args...)
+}
+func (r *recordingWrapper) Errorf(format string, args ...interface{}) {
+	r.record("ERROR", format, args...)
+}
+func (r *recordingWrapper) Panicf(format string, args ...interface{}) {
+	r.record("PANIC", format, args...)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := log.ParseConfig([]byte(`{"level":"warn","fields":["component"],"exclude":[{"field":"asset","value":"foo"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level == nil || *cfg.Level != log.LevelWarn {
+		t.Fatalf("want level warn, got %v", cfg.Level)
+	}
+	if len(cfg.Fields) != 1 || cfg.Fields[0] != fieldComponent {
+		t.Fatalf("unexpected fields %v", cfg.Fields)
+	}
+	if len(cfg.Exclude) != 1 || cfg.Exclude[0].Field != fieldAsset || cfg.Exclude[0].Value != "foo" {
+		t.Fatalf("unexpected exclude rules %v", cfg.Exclude)
+	}
+
+	for _, invalid := range []string{`{"level":"verbose"}`, `{"unknown":true}`, `{"exclude":[{"value":"foo"}]}`, `{`} {
+		if _, err := log.ParseConfig([]byte(invalid)); err == nil {
+			t.Fatalf("want error for %s", invalid)
+		}
+	}
+}
+
+func TestApplyConfigNumericExclude(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	logger := log.NewWithFactory(func() log.Wrapper {
+		return &recordingWrapper{mutex: &mutex, lines: &lines}
+	})
+	logger.RegisterField(fieldAsset)
+
+	cfg, err := log.ParseConfig([]byte(`{"exclude":[{"field":"asset","value":200}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.ApplyConfig(cfg)
+	logger.Info(context.WithValue(context.Background(), fieldAsset, 200), "this log should be excluded")
+	logger.Info(context.WithValue(context.Background(), fieldAsset, uint16(200)), "this log should be excluded too")
+	logger.Info(context.WithValue(context.Background(), fieldAsset, 404), "this is info")
+	logger.Info(context.WithValue(context.Background(), fieldAsset, "200"), "this is info too")
+
+	if len(lines) != 2 || lines[0] != "[INFO] this is info" || lines[1] != "[INFO] this is info too" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+}
+
+func TestApplyConfigKeepsSkip(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	logger := log.NewWithFactory(func() log.Wrapper {
+		return &recordingWrapper{mutex: &mutex, lines: &lines}
+	})
+	logger.RegisterField(fieldAsset)
+	logger.Skip(fieldAsset, "skipped in code")
+
+	logger.ApplyConfig(log.Config{Exclude: []log.ExcludeRule{{Field: fieldAsset, Value: "skipped by config"}}})
+	if got := logger.ApplyConfig(log.Config{Exclude: []log.ExcludeRule{{Field: fieldAsset, Value: "skipped by reload"}}}); len(got) != 1 ||
+		got[0] != "exclude rules [asset=skipped by config] -> [asset=skipped by reload]" {
+		t.Fatalf("unexpected changes %v", got)
+	}
+	for _, asset := range []string{"skipped in code", "skipped by config", "skipped by reload"} {
+		logger.Info(context.WithValue(context.Background(), fieldAsset, asset), "%s", asset)
+	}
+
+	if len(lines) != 1 || lines[0] != "[INFO] skipped by config" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	if got := logger.GetExcludeRules(); len(got) != 2 || got[0].Value != "skipped in code" || got[1].Value != "skipped by reload" {
+		t.Fatalf("unexpected exclude rules %v", got)
+	}
+}
+
+func containsFields(fields []log.Field, want ...log.Field) bool {
+	for _, w := range want {
+		var found bool
+		for _, f := range fields {
+			found = found || f == w
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestApplyConfigFields(t *testing.T) {
+	logger := log.New()
+	logger.RegisterField(fieldAsset)
+
+	if got := logger.ApplyConfig(log.Config{Fields: []log.Field{fieldComponent, fieldAsset}}); len(got) != 1 || got[0] != "fields added [component]" {
+		t.Fatalf("unexpected changes %v", got)
+	}
+	// A reload keeps the default fields and the ones registered in code
+	if got := logger.ApplyConfig(log.Config{Fields: []log.Field{}}); len(got) != 1 || got[0] != "fields removed [component]" {
+		t.Fatalf("unexpected changes %v", got)
+	}
+	if got := logger.GetRegisteredFields(); !containsFields(got, fieldAsset, log.FieldCaller, log.FieldSourceFile, log.FieldSourceLine, log.FieldStackTrace) || containsFields(got, fieldComponent) {
+		t.Fatalf("unexpected fields %v", got)
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	logger := log.NewWithFactory(func() log.Wrapper {
+		return &recordingWrapper{mutex: &mutex, lines: &lines}
+	})
+
+	path := filepath.Join(t.TempDir(), "log.json")
+	if err := os.WriteFile(path, []byte(`{"level":"info","fields":["component"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := logger.WatchConfigFile(ctx, path, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if logger.GetLevel() != log.LevelInfo {
+		t.Fatalf("want level info, got %s", logger.GetLevel())
+	}
+
+	waitFor := func(substr string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mutex.Lock()
+			for _, l := range lines {
+				if strings.Contains(l, substr) {
+					mutex.Unlock()
+					return
+				}
+			}
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("no log containing %q in %v", substr, lines)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			logger.Info(ctx, "concurrent log")
+		}
+	}()
+
+	if err := os.WriteFile(path, []byte(`{"level":"warn","fields":["asset"],"exclude":[{"field":"asset","value":"foo"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("[INFO] log config reloaded from " + path + ": level info -> warn, fields added [asset], fields removed [component], exclude rules [] -> [asset=foo]")
+	if got := logger.GetRegisteredFields(); !containsFields(got, fieldAsset, log.FieldCaller, log.FieldStackTrace) || containsFields(got, fieldComponent) {
+		t.Fatalf("unexpected fields %v", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"level":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("[ERROR] unable to reload log config from " + path)
+	if logger.GetLevel() != log.LevelWarn {
+		t.Fatalf("invalid config should keep level warn, got %s", logger.GetLevel())
+	}
+	if got := logger.GetExcludeRules(); len(got) != 1 {
+		t.Fatalf("invalid config should keep exclude rules, got %v", got)
+	}
+
+	cancel()
+	wg.Wait()
+}
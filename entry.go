@@ -0,0 +1,55 @@
+package log
+
+import (
+	"time"
+)
+
+// TimeWrapper is implemented by the wrappers able to log an entry at a given time instead of the current time.
+type TimeWrapper interface {
+	WithTime(t time.Time)
+}
+
+type entryField struct {
+	key   Field
+	value interface{}
+}
+
+// entry is a log entry whose fields are resolved, ready to be written to a Wrapper.
+type entry struct {
+	level  Level
+	time   time.Time
+	format string
+	args   []interface{}
+	fields []entryField
+}
+
+func (e entry) writeTo(w Wrapper) {
+	for _, f := range e.fields {
+		w.WithField(string(f.key), f.value)
+	}
+	if !e.time.IsZero() {
+		if tw, ok := w.(TimeWrapper); ok {
+			tw.WithTime(e.time)
+		}
+	}
+
+	switch e.level {
+	case LevelInfo:
+		w.Infof(e.format, e.args...)
+
+	case LevelWarn:
+		w.Warnf(e.format, e.args...)
+
+	case LevelError:
+		w.Errorf(e.format, e.args...)
+
+	case LevelFatal:
+		w.Fatalf(e.format, e.args...)
+
+	case LevelPanic:
+		w.Panicf(e.format, e.args...)
+
+	default:
+		w.Debugf(e.format, e.args...)
+	}
+}
@@ -0,0 +1,121 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	FieldErrorMessage = Field("error.message")
+	FieldErrorType    = Field("error.type")
+	FieldErrorChain   = Field("error.chain")
+)
+
+// ErrorWithFields is implemented by errors contributing their own fields to the log entries.
+type ErrorWithFields interface {
+	error
+	LogFields() map[Field]interface{}
+}
+
+// ContextWithError adds the message, the concrete type and the cause chain of err to the context,
+// as well as the fields contributed by the errors of the chain. They are emitted even if they are not registered.
+func ContextWithError(ctx context.Context, err error) context.Context {
+	if err == nil {
+		return ctx
+	}
+
+	values := make(map[Field]interface{})
+	chain := errorChain(err)
+	// Outer errors take precedence over their causes
+	for i := len(chain) - 1; i >= 0; i-- {
+		if withFields, ok := chain[i].(ErrorWithFields); ok {
+			for f, v := range withFields.LogFields() {
+				values[f] = v
+			}
+		}
+	}
+
+	values[FieldErrorMessage] = err.Error()
+	values[FieldErrorType] = fmt.Sprintf("%T", err)
+	if len(chain) > 1 {
+		causes := make([]string, 0, len(chain)-1)
+		for _, cause := range chain[1:] {
+			causes = append(causes, fmt.Sprintf("%T: %s", cause, cause))
+		}
+		values[FieldErrorChain] = causes
+	}
+
+	return contextWithFields(ctx, values)
+}
+
+// errorChain returns err followed by all the errors it wraps, depth first.
+func errorChain(err error) []error {
+	if err == nil {
+		return nil
+	}
+	chain := []error{err}
+	switch x := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, e := range x.Unwrap() {
+			chain = append(chain, errorChain(e)...)
+		}
+	case interface{ Unwrap() error }:
+		chain = append(chain, errorChain(x.Unwrap())...)
+	}
+	return chain
+}
+
+func (l *Logger) DebugErr(ctx context.Context, err error, format string, args ...interface{}) {
+	l.callErr(ctx, LevelDebug, err, format, args...)
+}
+
+func (l *Logger) InfoErr(ctx context.Context, err error, format string, args ...interface{}) {
+	l.callErr(ctx, LevelInfo, err, format, args...)
+}
+
+func (l *Logger) WarnErr(ctx context.Context, err error, format string, args ...interface{}) {
+	l.callErr(ctx, LevelWarn, err, format, args...)
+}
+
+func (l *Logger) ErrorErr(ctx context.Context, err error, format string, args ...interface{}) {
+	l.callErr(ctx, LevelError, err, format, args...)
+}
+
+func (l *Logger) FatalErr(ctx context.Context, err error, format string, args ...interface{}) {
+	l.callErr(ctx, LevelFatal, err, format, args...)
+}
+
+func (l *Logger) PanicErr(ctx context.Context, err error, format string, args ...interface{}) {
+	l.callErr(ctx, LevelPanic, err, format, args...)
+}
+
+func (l *Logger) callErr(ctx context.Context, level Level, err error, format string, args ...interface{}) {
+	if err != nil {
+		ctx = l.contextWithStackTrace(ContextWithError(ctx, err), err)
+	}
+	l.call(ctx, 0, level, format, args...)
+}
+
+func DebugErr(ctx context.Context, err error, format string, args ...interface{}) {
+	global.DebugErr(ctx, err, format, args...)
+}
+
+func InfoErr(ctx context.Context, err error, format string, args ...interface{}) {
+	global.InfoErr(ctx, err, format, args...)
+}
+
+func WarnErr(ctx context.Context, err error, format string, args ...interface{}) {
+	global.WarnErr(ctx, err, format, args...)
+}
+
+func ErrorErr(ctx context.Context, err error, format string, args ...interface{}) {
+	global.ErrorErr(ctx, err, format, args...)
+}
+
+func FatalErr(ctx context.Context, err error, format string, args ...interface{}) {
+	global.FatalErr(ctx, err, format, args...)
+}
+
+func PanicErr(ctx context.Context, err error, format string, args ...interface{}) {
+	global.PanicErr(ctx, err, format, args...)
+}
@@ -0,0 +1,32 @@
+package log_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rockbears/log"
+)
+
+type notFoundError struct {
+	asset string
+}
+
+func (e *notFoundError) Error() string {
+	return "asset " + e.asset + " not found"
+}
+
+func (e *notFoundError) LogFields() map[log.Field]interface{} {
+	return map[log.Field]interface{}{fieldAsset: e.asset}
+}
+
+func ExampleLogger_WarnErr() {
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{DisableTimestamp: true}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+
+	err := fmt.Errorf("unable to load: %w", &notFoundError{asset: "foo"})
+	logger.WarnErr(context.Background(), err, "this is %s", "warn")
+	logger.InfoErr(context.Background(), nil, "this is info")
+	// Output:
+	// [WARN] [asset=foo][error.chain=[*log_test.notFoundError: asset foo not found]][error.message=unable to load: asset foo not found][error.type=*fmt.wrapError] this is warn
+	// [INFO]  this is info
+}
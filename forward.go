@@ -0,0 +1,320 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+/* Fluentd Forward protocol wrapper, see https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1 */
+
+type ForwardMode int
+
+const (
+	// ForwardModeForward sends the entries of a tag as an array of [time, record]
+	ForwardModeForward ForwardMode = iota
+	// ForwardModePackedForward sends the entries of a tag as a binary MessagePack event stream
+	ForwardModePackedForward
+)
+
+type ForwardOptions struct {
+	// Network defaults to tcp, unix is supported too
+	Network string
+	Address string
+	// Tag defaults to app. TagField, if set, gives the tag of the entries having this field.
+	Tag      string
+	TagField Field
+	Mode     ForwardMode
+	Level    Level
+	// RequireAck waits for the server to acknowledge each chunk, for at most AckTimeout which defaults to 10 seconds
+	RequireAck bool
+	AckTimeout time.Duration
+
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxQueueSize  int
+	// MaxRetries defaults to 5, a negative value disables retries
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DialTimeout and WriteTimeout default to 5 seconds
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	// OnDrop is called when entries are dropped, because the queue is full or because they could not be sent
+	OnDrop func(err error, count int)
+}
+
+type forwardEvent struct {
+	tag    string
+	time   time.Time
+	record map[string]interface{}
+}
+
+// Forwarder sends entries to Fluentd or Fluent Bit by batches, reconnecting when sending fails.
+type Forwarder struct {
+	opts    ForwardOptions
+	mutex   sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	batcher *batcher[forwardEvent]
+}
+
+func NewForwarder(opts ForwardOptions) *Forwarder {
+	if opts.Network == "" {
+		opts.Network = "tcp"
+	}
+	if opts.Tag == "" {
+		opts.Tag = "app"
+	}
+	if opts.AckTimeout <= 0 {
+		opts.AckTimeout = 10 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	f := &Forwarder{opts: opts}
+	f.batcher = newBatcher(batcherOptions{
+		BatchSize:     opts.BatchSize,
+		FlushInterval: opts.FlushInterval,
+		MaxQueueSize:  opts.MaxQueueSize,
+		OnDrop:        opts.OnDrop,
+	}, f.send)
+	return f
+}
+
+// Flush sends the queued entries.
+func (f *Forwarder) Flush(ctx context.Context) error {
+	return f.batcher.Flush(ctx)
+}
+
+// Shutdown sends the queued entries and closes the connection.
+func (f *Forwarder) Shutdown(ctx context.Context) error {
+	err := f.batcher.Shutdown(ctx)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.conn != nil {
+		f.conn.Close()
+		f.conn = nil
+	}
+	return err
+}
+
+// Dropped returns the number of entries dropped so far.
+func (f *Forwarder) Dropped() uint64 {
+	return f.batcher.Dropped()
+}
+
+// send writes one message per tag, in the order of their first entry. The tags whose message could not be written do not
+// prevent the next ones from being sent, only their entries are reported as dropped.
+func (f *Forwarder) send(ctx context.Context, events []forwardEvent) error {
+	var tags []string
+	byTag := map[string][]forwardEvent{}
+	for _, e := range events {
+		if _, ok := byTag[e.tag]; !ok {
+			tags = append(tags, e.tag)
+		}
+		byTag[e.tag] = append(byTag[e.tag], e)
+	}
+
+	retryOpts := retryOptions{MaxRetries: f.opts.MaxRetries, InitialBackoff: f.opts.InitialBackoff, MaxBackoff: f.opts.MaxBackoff}
+	var errs []error
+	var failed int
+	for _, tag := range tags {
+		message, chunk := f.encode(tag, byTag[tag])
+		if err := retry(ctx, retryOpts, func() error { return f.write(ctx, message, chunk) }); err != nil {
+			errs = append(errs, fmt.Errorf("tag %s: %w", tag, err))
+			failed += len(byTag[tag])
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &partialSendError{err: errors.Join(errs...), count: failed}
+}
+
+func (f *Forwarder) encode(tag string, events []forwardEvent) ([]byte, string) {
+	var option map[string]interface{}
+	var chunk string
+	if f.opts.RequireAck {
+		id := make([]byte, 16)
+		_, _ = rand.Read(id)
+		chunk = base64.StdEncoding.EncodeToString(id)
+		option = map[string]interface{}{"chunk": chunk}
+	}
+
+	var entries msgpackEncoder
+	if f.opts.Mode == ForwardModeForward {
+		entries.appendArrayHeader(len(events))
+	}
+	for _, e := range events {
+		entries.appendArrayHeader(2)
+		entries.appendEventTime(e.time)
+		entries.appendStringMap(e.record)
+	}
+
+	var enc msgpackEncoder
+	if option != nil {
+		enc.appendArrayHeader(3)
+	} else {
+		enc.appendArrayHeader(2)
+	}
+	enc.appendString(tag)
+	if f.opts.Mode == ForwardModePackedForward {
+		enc.appendBinary(entries.buf)
+	} else {
+		enc.buf = append(enc.buf, entries.buf...)
+	}
+	if option != nil {
+		enc.appendStringMap(option)
+	}
+	return enc.buf, chunk
+}
+
+// write sends a message and waits for its ack if chunk is set. The connection is closed on failure, to be dialed again.
+// The deadline of ctx shortens the timeouts.
+func (f *Forwarder) write(ctx context.Context, message []byte, chunk string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deadline := func(timeout time.Duration) time.Time {
+		d := time.Now().Add(timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(d) {
+			return ctxDeadline
+		}
+		return d
+	}
+
+	if f.conn == nil {
+		dialer := net.Dialer{Timeout: f.opts.DialTimeout}
+		conn, err := dialer.DialContext(ctx, f.opts.Network, f.opts.Address)
+		if err != nil {
+			return &retryableError{err: err}
+		}
+		f.conn, f.reader = conn, bufio.NewReader(conn)
+	}
+
+	err := func() error {
+		_ = f.conn.SetWriteDeadline(deadline(f.opts.WriteTimeout))
+		if _, err := f.conn.Write(message); err != nil {
+			return err
+		}
+		if chunk == "" {
+			return nil
+		}
+		_ = f.conn.SetReadDeadline(deadline(f.opts.AckTimeout))
+		resp, err := readMsgpackStringMap(f.reader)
+		if err != nil {
+			return err
+		}
+		if resp["ack"] != chunk {
+			return fmt.Errorf("unexpected ack %q for chunk %q", resp["ack"], chunk)
+		}
+		return nil
+	}()
+	if err != nil {
+		f.conn.Close()
+		f.conn, f.reader = nil, nil
+		return &retryableError{err: err}
+	}
+	return nil
+}
+
+func NewForwardWrapper(f *Forwarder) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &ForwardWrapper{forwarder: f}
+	}
+}
+
+type ForwardWrapper struct {
+	forwarder *Forwarder
+	tag       string
+	record    map[string]interface{}
+	time      time.Time
+}
+
+func (l *ForwardWrapper) GetLevel() Level {
+	return l.forwarder.opts.Level
+}
+
+func (l *ForwardWrapper) WithField(key string, value interface{}) {
+	if l.record == nil {
+		l.record = map[string]interface{}{}
+	}
+	if l.forwarder.opts.TagField != "" && key == string(l.forwarder.opts.TagField) {
+		l.tag = fmt.Sprintf("%v", value)
+	}
+	// The message and the level of the entry are not overwritten
+	if key == "message" || key == "level" {
+		key = "field_" + key
+	}
+	l.record[key] = value
+}
+
+func (l *ForwardWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *ForwardWrapper) add(level Level, format string, args ...interface{}) {
+	e := forwardEvent{tag: l.tag, time: l.time, record: l.record}
+	if e.tag == "" {
+		e.tag = l.forwarder.opts.Tag
+	}
+	if e.time.IsZero() {
+		e.time = time.Now()
+	}
+	if e.record == nil {
+		e.record = map[string]interface{}{}
+	}
+	e.record["message"] = getFormatedMsg(format, args...)
+	e.record["level"] = level.String()
+	l.forwarder.batcher.Add(e)
+}
+
+func (l *ForwardWrapper) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = l.forwarder.Flush(ctx)
+}
+
+func (l *ForwardWrapper) Debugf(format string, args ...interface{}) {
+	l.add(LevelDebug, format, args...)
+}
+
+func (l *ForwardWrapper) Infof(format string, args ...interface{}) {
+	l.add(LevelInfo, format, args...)
+}
+
+func (l *ForwardWrapper) Warnf(format string, args ...interface{}) {
+	l.add(LevelWarn, format, args...)
+}
+
+func (l *ForwardWrapper) Fatalf(format string, args ...interface{}) {
+	l.add(LevelFatal, format, args...)
+	l.flush()
+	os.Exit(1)
+}
+
+func (l *ForwardWrapper) Errorf(format string, args ...interface{}) {
+	l.add(LevelError, format, args...)
+}
+
+func (l *ForwardWrapper) Panicf(format string, args ...interface{}) {
+	l.add(LevelPanic, format, args...)
+	l.flush()
+	panic(getFormatedMsg(format, args...))
+}
@@ -0,0 +1,335 @@
+package log_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+// decodeMsgpack decodes the subset of MessagePack written by the Forward wrapper.
+func decodeMsgpack(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readN := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return buf, err
+	}
+	readUint := func(size int) (uint64, error) {
+		buf, err := readN(size)
+		if err != nil {
+			return 0, err
+		}
+		var u uint64
+		for _, c := range buf {
+			u = u<<8 | uint64(c)
+		}
+		return u, nil
+	}
+	array := func(n int) (interface{}, error) {
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	object := func(n int) (interface{}, error) {
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[fmt.Sprint(k)], err = decodeMsgpack(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return object(int(b & 0x0f))
+	case b&0xf0 == 0x90:
+		return array(int(b & 0x0f))
+	case b&0xe0 == 0xa0:
+		buf, err := readN(int(b & 0x1f))
+		return string(buf), err
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return b == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[b]
+		n, err := readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := readN(int(n))
+		if b >= 0xd9 {
+			return string(buf), err
+		}
+		return buf, err
+	case 0xcb:
+		u, err := readUint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := readUint(1 << (b - 0xcc))
+		return int64(u), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		u, err := readUint(size)
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xd7:
+		buf, err := readN(9)
+		if err != nil || buf[0] != 0 {
+			return nil, fmt.Errorf("unexpected extension %v: %v", buf, err)
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(buf[1:5])), int64(binary.BigEndian.Uint32(buf[5:9]))).UTC(), nil
+	case 0xdc, 0xde:
+		n, err := readUint(2)
+		if err != nil {
+			return nil, err
+		}
+		if b == 0xdc {
+			return array(int(n))
+		}
+		return object(int(n))
+	}
+	return nil, fmt.Errorf("unsupported msgpack type 0x%02x", b)
+}
+
+type forwardMessage struct {
+	tag     string
+	entries []interface{}
+	option  map[string]interface{}
+}
+
+// serveForward decodes the messages received on the first connection, acknowledging their chunks.
+func serveForward(ln net.Listener, messages chan<- forwardMessage) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			return
+		}
+		a := v.([]interface{})
+		m := forwardMessage{tag: a[0].(string)}
+		switch entries := a[1].(type) {
+		case []interface{}:
+			m.entries = entries
+		case []byte:
+			er := bufio.NewReader(bytes.NewReader(entries))
+			for {
+				e, err := decodeMsgpack(er)
+				if err != nil {
+					break
+				}
+				m.entries = append(m.entries, e)
+			}
+		}
+		if len(a) > 2 {
+			m.option = a[2].(map[string]interface{})
+			if chunk, ok := m.option["chunk"].(string); ok {
+				// {"ack": chunk}
+				ack := append([]byte{0x81, 0xa3, 'a', 'c', 'k', 0xd9, byte(len(chunk))}, chunk...)
+				if _, err := conn.Write(ack); err != nil {
+					return
+				}
+			}
+		}
+		messages <- m
+	}
+}
+
+func TestForward(t *testing.T) {
+	for name, mode := range map[string]log.ForwardMode{"forward": log.ForwardModeForward, "packed forward": log.ForwardModePackedForward} {
+		t.Run(name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			messages := make(chan forwardMessage, 10)
+			go serveForward(ln, messages)
+
+			f := log.NewForwarder(log.ForwardOptions{Address: ln.Addr().String(), Tag: "myapp", TagField: fieldComponent, Mode: mode, RequireAck: true})
+			defer f.Shutdown(context.Background())
+			logger := log.NewWithFactory(log.NewForwardWrapper(f))
+			logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+			logger.RegisterField(fieldComponent, fieldAsset)
+			now := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+			logger.SetClock(func() time.Time { return now })
+
+			logger.Info(context.WithValue(context.Background(), fieldAsset, 42), "first")
+			logger.Warn(context.WithValue(context.Background(), fieldComponent, "api"), "second")
+			logger.Info(context.Background(), "third")
+			if err := f.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range []struct {
+				tag      string
+				messages []string
+			}{{"myapp", []string{"first", "third"}}, {"api", []string{"second"}}} {
+				m := <-messages
+				if m.tag != want.tag || len(m.entries) != len(want.messages) {
+					t.Fatalf("unexpected message %+v", m)
+				}
+				for i, e := range m.entries {
+					entry := e.([]interface{})
+					record := entry[1].(map[string]interface{})
+					if !entry[0].(time.Time).Equal(now) || record["message"] != want.messages[i] {
+						t.Fatalf("unexpected entry %v", entry)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestForwardReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	messages := make(chan forwardMessage, 10)
+	go func() {
+		// The first connection is closed right away, the forwarder must dial again
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		serveForward(ln, messages)
+	}()
+
+	f := log.NewForwarder(log.ForwardOptions{Address: ln.Addr().String(), RequireAck: true, InitialBackoff: 10 * time.Millisecond})
+	defer f.Shutdown(context.Background())
+	logger := log.NewWithFactory(log.NewForwardWrapper(f))
+	logger.Info(context.Background(), "this is info")
+	if err := f.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m := <-messages
+	if record := m.entries[0].([]interface{})[1].(map[string]interface{}); record["message"] != "this is info" || record["level"] != "info" {
+		t.Fatalf("unexpected record %v", record)
+	}
+	if f.Dropped() != 0 {
+		t.Fatalf("no entry should be dropped, got %d", f.Dropped())
+	}
+}
+
+func TestForwardPartialDrop(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	tags := make(chan string, 10)
+	go func() {
+		// The messages of the bad tag are never acknowledged
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for {
+				v, err := decodeMsgpack(r)
+				if err != nil {
+					break
+				}
+				a := v.([]interface{})
+				tag, chunk := a[0].(string), a[2].(map[string]interface{})["chunk"].(string)
+				if tag == "bad" {
+					break
+				}
+				tags <- tag
+				ack := append([]byte{0x81, 0xa3, 'a', 'c', 'k', 0xd9, byte(len(chunk))}, chunk...)
+				if _, err := conn.Write(ack); err != nil {
+					break
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	var dropped int
+	f := log.NewForwarder(log.ForwardOptions{
+		Address:    ln.Addr().String(),
+		TagField:   fieldComponent,
+		RequireAck: true,
+		MaxRetries: -1,
+		OnDrop:     func(err error, count int) { dropped += count },
+	})
+	defer f.Shutdown(context.Background())
+	logger := log.NewWithFactory(log.NewForwardWrapper(f))
+	logger.RegisterField(fieldComponent)
+	logger.Info(context.WithValue(context.Background(), fieldComponent, "bad"), "first")
+	logger.Info(context.WithValue(context.Background(), fieldComponent, "bad"), "second")
+	logger.Info(context.Background(), "third")
+	if err := f.Flush(context.Background()); err == nil {
+		t.Fatal("want error")
+	}
+
+	if tag := <-tags; tag != "app" {
+		t.Fatalf("unexpected tag %s", tag)
+	}
+	if dropped != 2 || f.Dropped() != 2 {
+		t.Fatalf("want the 2 entries of the bad tag dropped, got %d", dropped)
+	}
+}
+
+func TestForwardReservedKeys(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	messages := make(chan forwardMessage, 10)
+	go serveForward(ln, messages)
+
+	f := log.NewForwarder(log.ForwardOptions{Address: ln.Addr().String()})
+	defer f.Shutdown(context.Background())
+	logger := log.NewWithFactory(log.NewForwardWrapper(f))
+	logger.RegisterField("message", "level")
+	ctx := context.WithValue(context.Background(), log.Field("message"), "user message")
+	ctx = context.WithValue(ctx, log.Field("level"), "user level")
+	logger.Warn(ctx, "this is warn")
+	if err := f.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	record := (<-messages).entries[0].([]interface{})[1].(map[string]interface{})
+	if record["message"] != "this is warn" || record["level"] != "warn" ||
+		record["field_message"] != "user message" || record["field_level"] != "user level" {
+		t.Fatalf("unexpected record %v", record)
+	}
+}
@@ -0,0 +1,307 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* GELF wrapper */
+
+type GELFCompression int
+
+const (
+	GELFCompressionNone GELFCompression = iota
+	GELFCompressionGzip
+	GELFCompressionZlib
+)
+
+type GELFOptions struct {
+	// Network is udp or tcp. Messages are chunked and may be compressed over udp, they are terminated by a null byte over tcp.
+	Network string
+	Address string
+	// Host defaults to the name of the host
+	Host string
+	// Facility, if set, is sent as the _facility additional field
+	Facility    string
+	Level       Level
+	Compression GELFCompression
+	// ChunkSize defaults to 1420 bytes, the size of the UDP datagrams including the chunk header
+	ChunkSize int
+	// DialTimeout and WriteTimeout default to 5 seconds
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	// OnError is called when a message cannot be sent, even after reconnecting
+	OnError func(err error)
+}
+
+const (
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// GELF sends messages to Graylog, reconnecting when sending fails. It is safe for concurrent use.
+type GELF struct {
+	opts  GELFOptions
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+func NewGELF(opts GELFOptions) (*GELF, error) {
+	switch opts.Network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported GELF network %q", opts.Network)
+	}
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	if opts.ChunkSize <= gelfChunkHeaderSize {
+		opts.ChunkSize = 1420
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	g := &GELF{opts: opts}
+	if err := g.connect(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// connect must be called with the mutex held.
+func (g *GELF) connect() error {
+	conn, err := net.DialTimeout(g.opts.Network, g.opts.Address, g.opts.DialTimeout)
+	if err != nil {
+		return err
+	}
+	g.conn = conn
+	return nil
+}
+
+func (g *GELF) isStream() bool {
+	return strings.HasPrefix(g.opts.Network, "tcp")
+}
+
+func (g *GELF) Close() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+func (g *GELF) send(message []byte) {
+	packets, err := g.packets(message)
+	if err != nil {
+		if g.opts.OnError != nil {
+			g.opts.OnError(err)
+		}
+		return
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if g.conn == nil {
+			if err = g.connect(); err != nil {
+				continue
+			}
+		}
+		_ = g.conn.SetWriteDeadline(time.Now().Add(g.opts.WriteTimeout))
+		for _, p := range packets {
+			if _, err = g.conn.Write(p); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return
+		}
+		g.conn.Close()
+		g.conn = nil
+	}
+	if g.opts.OnError != nil {
+		g.opts.OnError(err)
+	}
+}
+
+// packets returns the null terminated message over tcp, the compressed message or its chunks over udp.
+func (g *GELF) packets(message []byte) ([][]byte, error) {
+	if g.isStream() {
+		return [][]byte{append(message, 0)}, nil
+	}
+
+	var buf bytes.Buffer
+	switch g.opts.Compression {
+	case GELFCompressionGzip:
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(message)
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		message = buf.Bytes()
+	case GELFCompressionZlib:
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(message)
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		message = buf.Bytes()
+	}
+	if len(message) <= g.opts.ChunkSize {
+		return [][]byte{message}, nil
+	}
+
+	size := g.opts.ChunkSize - gelfChunkHeaderSize
+	count := (len(message) + size - 1) / size
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("GELF message of %d bytes exceeds %d chunks", len(message), gelfMaxChunks)
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	packets := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		chunk := message[i*size : min((i+1)*size, len(message))]
+		p := make([]byte, 0, gelfChunkHeaderSize+len(chunk))
+		p = append(p, 0x1e, 0x0f)
+		p = append(p, id...)
+		p = append(p, byte(i), byte(count))
+		packets = append(packets, append(p, chunk...))
+	}
+	return packets, nil
+}
+
+var gelfInvalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+// gelfFieldName returns the name of an additional field, prefixed by an underscore. _id is reserved.
+func gelfFieldName(key string) string {
+	name := "_" + gelfInvalidFieldChars.ReplaceAllString(key, "_")
+	if name == "_id" {
+		return "__id"
+	}
+	return name
+}
+
+// gelfFieldValue returns a string or a number, the only types of the additional fields.
+func gelfFieldValue(value interface{}) interface{} {
+	switch x := value.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return x
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+func NewGELFWrapper(g *GELF) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &GELFWrapper{gelf: g}
+	}
+}
+
+type GELFWrapper struct {
+	gelf   *GELF
+	fields []entryField
+	time   time.Time
+}
+
+func (l *GELFWrapper) GetLevel() Level {
+	return l.gelf.opts.Level
+}
+
+func (l *GELFWrapper) WithField(key string, value interface{}) {
+	l.fields = append(l.fields, entryField{key: Field(key), value: value})
+}
+
+func (l *GELFWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *GELFWrapper) send(level Level, format string, args ...interface{}) {
+	t := l.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg := getFormatedMsg(format, args...)
+	message := map[string]interface{}{
+		"version":   "1.1",
+		"host":      l.gelf.opts.Host,
+		"timestamp": json.Number(fmt.Sprintf("%d.%03d", t.Unix(), t.Nanosecond()/int(time.Millisecond))),
+		"level":     syslogSeverities[level],
+	}
+	if l.gelf.opts.Facility != "" {
+		message["_facility"] = l.gelf.opts.Facility
+	}
+
+	// The first line is the short message, the full message holds the other lines and the stack trace
+	short, _, multiline := strings.Cut(msg, "\n")
+	message["short_message"] = short
+	full := ""
+	if multiline {
+		full = msg
+	}
+	for _, f := range l.fields {
+		if f.key == FieldStackTrace {
+			if full == "" {
+				full = msg
+			}
+			full += "\n" + strings.TrimLeft(fmt.Sprintf("%v", f.value), "\n")
+			continue
+		}
+		message[gelfFieldName(string(f.key))] = gelfFieldValue(f.value)
+	}
+	if full != "" {
+		message["full_message"] = full
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		if l.gelf.opts.OnError != nil {
+			l.gelf.opts.OnError(err)
+		}
+		return
+	}
+	l.gelf.send(body)
+}
+
+func (l *GELFWrapper) Debugf(format string, args ...interface{}) {
+	l.send(LevelDebug, format, args...)
+}
+
+func (l *GELFWrapper) Infof(format string, args ...interface{}) {
+	l.send(LevelInfo, format, args...)
+}
+
+func (l *GELFWrapper) Warnf(format string, args ...interface{}) {
+	l.send(LevelWarn, format, args...)
+}
+
+func (l *GELFWrapper) Fatalf(format string, args ...interface{}) {
+	l.send(LevelFatal, format, args...)
+	os.Exit(1)
+}
+
+func (l *GELFWrapper) Errorf(format string, args ...interface{}) {
+	l.send(LevelError, format, args...)
+}
+
+func (l *GELFWrapper) Panicf(format string, args ...interface{}) {
+	l.send(LevelPanic, format, args...)
+	panic(getFormatedMsg(format, args...))
+}
@@ -0,0 +1,155 @@
+package log_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rockbears/log"
+)
+
+// readGELFMessage reads datagrams until a message is complete, reassembling the chunks and decompressing it.
+func readGELFMessage(t *testing.T, conn net.PacketConn) map[string]interface{} {
+	t.Helper()
+	chunks := map[byte][]byte{}
+	var payload []byte
+	for payload == nil {
+		buf := make([]byte, 65536)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := buf[:n]
+		if len(p) < 12 || p[0] != 0x1e || p[1] != 0x0f {
+			payload = p
+			break
+		}
+		chunks[p[10]] = p[12:]
+		if count := int(p[11]); len(chunks) == count {
+			for i := 0; i < count; i++ {
+				payload = append(payload, chunks[byte(i)]...)
+			}
+		}
+	}
+
+	var r io.Reader = bytes.NewReader(payload)
+	switch {
+	case payload[0] == 0x1f && payload[1] == 0x8b:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case payload[0] == 0x78:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	var message map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func newGELFLogger(t *testing.T, opts log.GELFOptions) *log.Logger {
+	t.Helper()
+	opts.Host, opts.Facility = "myhost", "myapp"
+	g, err := log.NewGELF(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	logger := log.NewWithFactory(log.NewGELFWrapper(g))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.RegisterField(fieldComponent, log.Field("id"))
+	logger.SetClock(func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 250000000, time.UTC) })
+	return logger
+}
+
+func TestGELFUDP(t *testing.T) {
+	for _, compression := range []log.GELFCompression{log.GELFCompressionNone, log.GELFCompressionGzip, log.GELFCompressionZlib} {
+		t.Run(fmt.Sprint(compression), func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			logger := newGELFLogger(t, log.GELFOptions{Network: "udp", Address: conn.LocalAddr().String(), Compression: compression, ChunkSize: 100})
+
+			ctx := context.WithValue(context.Background(), fieldComponent, "api")
+			ctx = context.WithValue(ctx, log.Field("id"), 42)
+			logger.ErrorWithStackTrace(ctx, errors.New("this is an error"))
+
+			message := readGELFMessage(t, conn)
+			for k, v := range map[string]interface{}{
+				"version":       "1.1",
+				"host":          "myhost",
+				"short_message": "this is an error",
+				"level":         float64(3),
+				"timestamp":     1714564800.25,
+				"_facility":     "myapp",
+				"_component":    "api",
+				"__id":          float64(42),
+			} {
+				if message[k] != v {
+					t.Fatalf("want %s=%v, got %v in %v", k, v, message[k], message)
+				}
+			}
+			if full, _ := message["full_message"].(string); !strings.HasPrefix(full, "this is an error\n") || !strings.Contains(full, "TestGELFUDP") {
+				t.Fatalf("unexpected full message %q", full)
+			}
+		})
+	}
+}
+
+func TestGELFTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	logger := newGELFLogger(t, log.GELFOptions{Network: "tcp", Address: ln.Addr().String()})
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	logger.Info(context.Background(), "first")
+	logger.Warn(context.Background(), "second\nwith details")
+
+	r := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []map[string]interface{}{
+		{"short_message": "first", "level": float64(6)},
+		{"short_message": "second", "full_message": "second\nwith details", "level": float64(4)},
+	} {
+		frame, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal(frame[:len(frame)-1], &message); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range want {
+			if message[k] != v {
+				t.Fatalf("want %s=%v, got %v in %v", k, v, message[k], message)
+			}
+		}
+	}
+}
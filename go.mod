@@ -6,9 +6,7 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.9.3
 	go.uber.org/zap v1.27.1
+	golang.org/x/sys v0.39.0
 )
 
-require (
-	go.uber.org/multierr v1.11.0 // indirect
-	golang.org/x/sys v0.39.0 // indirect
-)
+require go.uber.org/multierr v1.11.0 // indirect
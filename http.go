@@ -0,0 +1,184 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	FieldRequestID    = Field("request_id")
+	FieldHTTPMethod   = Field("http_method")
+	FieldHTTPPath     = Field("http_path")
+	FieldRemoteAddr   = Field("remote_addr")
+	FieldHTTPStatus   = Field("http_status")
+	FieldHTTPBytes    = Field("http_bytes")
+	FieldHTTPDuration = Field("http_duration")
+)
+
+type HTTPMiddlewareOptions struct {
+	// RequestIDHeader defaults to X-Request-ID
+	RequestIDHeader string
+	// GenerateRequestID ignores the request ID received with the request
+	GenerateRequestID bool
+	// LevelFunc chooses the level of the access log from the status code. By default 5xx are errors, 4xx are warnings.
+	LevelFunc        func(status int) Level
+	DisableAccessLog bool
+	// Propagation, if set, extracts the fields sent by the client with NewFieldTransport
+	Propagation *FieldPropagation
+}
+
+func defaultHTTPLevel(status int) Level {
+	switch {
+	case status >= 500:
+		return LevelError
+	case status >= 400:
+		return LevelWarn
+	default:
+		return LevelInfo
+	}
+}
+
+// HTTPMiddleware adds the request ID, method, path and remote address of the requests to their context,
+// and logs the requests once they are served. The global logger is used if logger is nil.
+func HTTPMiddleware(logger *Logger, opts HTTPMiddlewareOptions) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = global
+	}
+	if opts.RequestIDHeader == "" {
+		opts.RequestIDHeader = "X-Request-ID"
+	}
+	if opts.LevelFunc == nil {
+		opts.LevelFunc = defaultHTTPLevel
+	}
+	logger.RegisterField(FieldRequestID, FieldHTTPMethod, FieldHTTPPath, FieldRemoteAddr, FieldHTTPStatus, FieldHTTPBytes, FieldHTTPDuration)
+	if opts.Propagation != nil {
+		logger.RegisterField(opts.Propagation.Fields...)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(opts.RequestIDHeader)
+			if opts.GenerateRequestID || !isValidRequestID(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set(opts.RequestIDHeader, requestID)
+
+			ctx := r.Context()
+			if opts.Propagation != nil {
+				ctx = opts.Propagation.Extract(ctx, r.Header)
+			}
+			ctx = context.WithValue(ctx, FieldRequestID, requestID)
+			ctx = context.WithValue(ctx, FieldHTTPMethod, r.Method)
+			ctx = context.WithValue(ctx, FieldHTTPPath, r.URL.Path)
+			ctx = context.WithValue(ctx, FieldRemoteAddr, r.RemoteAddr)
+			if tp, err := ParseTraceParent(r.Header.Get("traceparent")); err == nil {
+				ctx = ContextWithTraceParent(ctx, tp)
+			}
+
+			rw := &responseWriter{ResponseWriter: w}
+			if !opts.DisableAccessLog {
+				defer func() {
+					recovered := recover()
+					if recovered != nil && !rw.wroteHeader {
+						rw.status, rw.wroteHeader = http.StatusInternalServerError, true
+					}
+					logAccess(ctx, logger, opts, rw, time.Since(start))
+					if recovered != nil {
+						panic(recovered)
+					}
+				}()
+			}
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+func logAccess(ctx context.Context, logger *Logger, opts HTTPMiddlewareOptions, rw *responseWriter, duration time.Duration) {
+	status := rw.status
+	if !rw.wroteHeader {
+		status = http.StatusOK
+	}
+	ctx = context.WithValue(ctx, FieldHTTPStatus, status)
+	ctx = context.WithValue(ctx, FieldHTTPBytes, rw.bytes)
+	ctx = context.WithValue(ctx, FieldHTTPDuration, duration)
+	logger.call(ctx, 0, opts.LevelFunc(status), "%s %s %d %s", ctx.Value(FieldHTTPMethod), ctx.Value(FieldHTTPPath), status, duration)
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// responseWriter records the status code and the number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// 1xx responses other than 101 Switching Protocols are not final
+	if !w.wroteHeader && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	if !w.wroteHeader {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return h.Hijack()
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
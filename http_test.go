@@ -0,0 +1,92 @@
+package log_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	var fields []map[string]interface{}
+	logger := log.NewWithFactory(func() log.Wrapper {
+		return &recordingWrapper{mutex: &mutex, lines: &lines, fields: &fields}
+	})
+
+	handler := log.HTTPMiddleware(logger, log.HTTPMiddlewareOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Info(r.Context(), "handling request")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("X-Request-ID", "my-request-id")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "my-request-id" {
+		t.Fatalf("want propagated request id, got %q", got)
+	}
+	if len(lines) != 2 || lines[0] != "[INFO] handling request" {
+		t.Fatalf("unexpected logs %v", lines)
+	}
+	for _, f := range fields {
+		if f["request_id"] != "my-request-id" || f["http_method"] != "GET" || f["http_path"] != "/hello" || f["remote_addr"] != "192.0.2.1:1234" ||
+			f["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("unexpected fields %v", f)
+		}
+	}
+	access := fields[1]
+	if access["http_status"] != 200 || access["http_bytes"] != int64(5) || access["http_duration"].(time.Duration) <= 0 {
+		t.Fatalf("unexpected access log fields %v", access)
+	}
+	if _, has := fields[0]["http_status"]; has {
+		t.Fatalf("unexpected status in handler logs %v", fields[0])
+	}
+
+	lines, fields = nil, nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/missing", nil))
+	requestID := rec.Header().Get("X-Request-ID")
+	if len(requestID) != 32 {
+		t.Fatalf("want a generated request id, got %q", requestID)
+	}
+	if len(lines) != 2 || lines[1][:6] != "[WARN]" || fields[1]["http_status"] != 404 || fields[1]["request_id"] != requestID {
+		t.Fatalf("unexpected access log %v %v", lines, fields)
+	}
+}
+
+func TestHTTPMiddlewarePanic(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	var fields []map[string]interface{}
+	logger := log.NewWithFactory(func() log.Wrapper {
+		return &recordingWrapper{mutex: &mutex, lines: &lines, fields: &fields}
+	})
+	handler := log.HTTPMiddleware(logger, log.HTTPMiddlewareOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("want the panic to go through, got %v", r)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(context.Background()))
+	}()
+
+	if len(lines) != 1 || lines[0][:7] != "[ERROR]" || fields[0]["http_status"] != 500 {
+		t.Fatalf("unexpected access log %v %v", lines, fields)
+	}
+}
@@ -0,0 +1,185 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* journald wrapper */
+
+type JournaldOptions struct {
+	// SocketPath defaults to /run/systemd/journal/socket
+	SocketPath string
+	Level      Level
+	// Identifier is the SYSLOG_IDENTIFIER of the entries, it defaults to the name of the executable
+	Identifier string
+	// OnError is called when an entry cannot be sent
+	OnError func(err error)
+}
+
+// Journald sends entries to the systemd journal with its native protocol. It is only supported on Linux.
+type Journald struct {
+	opts JournaldOptions
+	conn *journaldConn
+}
+
+func NewJournald(opts JournaldOptions) (*Journald, error) {
+	if opts.SocketPath == "" {
+		opts.SocketPath = "/run/systemd/journal/socket"
+	}
+	if opts.Identifier == "" {
+		opts.Identifier = filepath.Base(os.Args[0])
+	}
+	conn, err := newJournaldConn(opts.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Journald{opts: opts, conn: conn}, nil
+}
+
+func (j *Journald) Close() error {
+	return j.conn.close()
+}
+
+func (j *Journald) send(payload []byte) {
+	if err := j.conn.send(payload); err != nil && j.opts.OnError != nil {
+		j.opts.OnError(err)
+	}
+}
+
+// journaldFieldName returns a valid journal field name: uppercase letters, digits and underscores,
+// not starting with an underscore or a digit, at most 64 characters.
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// journaldReservedFields are the fields written by JournaldWrapper itself. The fields of the entries with the same name are
+// prefixed by FIELD_, for the journal not to store two values.
+var journaldReservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// journaldUserFieldName returns the name of the field of an entry, which must not be one of the reserved fields.
+func journaldUserFieldName(key string) string {
+	name := journaldFieldName(key)
+	if journaldReservedFields[name] {
+		return journaldFieldName("FIELD_" + name)
+	}
+	return name
+}
+
+// appendJournaldField encodes a field, values with newlines are prefixed by their length as a little endian uint64.
+func appendJournaldField(buf *bytes.Buffer, name, value string) {
+	if name == "" {
+		return
+	}
+	buf.WriteString(name)
+	if strings.ContainsRune(value, '\n') {
+		buf.WriteByte('\n')
+		_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	} else {
+		buf.WriteByte('=')
+	}
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func NewJournaldWrapper(j *Journald) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &JournaldWrapper{journald: j}
+	}
+}
+
+type JournaldWrapper struct {
+	journald *Journald
+	fields   []entryField
+}
+
+func (l *JournaldWrapper) GetLevel() Level {
+	return l.journald.opts.Level
+}
+
+func (l *JournaldWrapper) WithField(key string, value interface{}) {
+	l.fields = append(l.fields, entryField{key: Field(key), value: value})
+}
+
+func (l *JournaldWrapper) send(level Level, format string, args ...interface{}) {
+	var buf bytes.Buffer
+	appendJournaldField(&buf, "MESSAGE", getFormatedMsg(format, args...))
+	appendJournaldField(&buf, "PRIORITY", strconv.Itoa(syslogSeverities[level]))
+	appendJournaldField(&buf, "SYSLOG_IDENTIFIER", l.journald.opts.Identifier)
+	for _, f := range l.fields {
+		switch v := f.value.(type) {
+		case Caller:
+			appendJournaldField(&buf, "CODE_FILE", v.File)
+			appendJournaldField(&buf, "CODE_LINE", strconv.Itoa(v.Line))
+			appendJournaldField(&buf, "CODE_FUNC", v.Function)
+			continue
+		case time.Duration:
+			appendJournaldField(&buf, journaldUserFieldName(string(f.key)), v.String())
+			continue
+		}
+		name := journaldUserFieldName(string(f.key))
+		switch f.key {
+		case FieldSourceFile:
+			name = "CODE_FILE"
+		case FieldSourceLine:
+			name = "CODE_LINE"
+		case FieldCaller:
+			name = "CODE_FUNC"
+		}
+		appendJournaldField(&buf, name, fmt.Sprintf("%v", f.value))
+	}
+	l.journald.send(buf.Bytes())
+}
+
+func (l *JournaldWrapper) Debugf(format string, args ...interface{}) {
+	l.send(LevelDebug, format, args...)
+}
+
+func (l *JournaldWrapper) Infof(format string, args ...interface{}) {
+	l.send(LevelInfo, format, args...)
+}
+
+func (l *JournaldWrapper) Warnf(format string, args ...interface{}) {
+	l.send(LevelWarn, format, args...)
+}
+
+func (l *JournaldWrapper) Fatalf(format string, args ...interface{}) {
+	l.send(LevelFatal, format, args...)
+	os.Exit(1)
+}
+
+func (l *JournaldWrapper) Errorf(format string, args ...interface{}) {
+	l.send(LevelError, format, args...)
+}
+
+func (l *JournaldWrapper) Panicf(format string, args ...interface{}) {
+	l.send(LevelPanic, format, args...)
+	panic(getFormatedMsg(format, args...))
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package log
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+type journaldConn struct {
+	mutex sync.Mutex
+	conn  *net.UnixConn
+	addr  *net.UnixAddr
+}
+
+func newJournaldConn(socketPath string) (*journaldConn, error) {
+	c := &journaldConn{addr: &net.UnixAddr{Name: socketPath, Net: "unixgram"}}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial must be called with the mutex held.
+func (c *journaldConn) dial() error {
+	conn, err := net.DialUnix("unixgram", nil, c.addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *journaldConn) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *journaldConn) send(payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var err error
+	// The socket is dialed again once, in case journald restarted
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil {
+			if err = c.dial(); err != nil {
+				continue
+			}
+		}
+		if err = c.write(payload); err == nil {
+			return nil
+		}
+		if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+			return err
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+// write must be called with the mutex held.
+func (c *journaldConn) write(payload []byte) error {
+	err := c.sendmsg(payload, nil)
+	if err == nil || !(errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		return err
+	}
+
+	// Entries too large for a datagram are written to a sealed memfd, or to a deleted temporary file,
+	// whose descriptor is sent instead
+	file, err := journaldPayloadFile(payload)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return c.sendmsg(nil, syscall.UnixRights(int(file.Fd())))
+}
+
+// sendmsg must be called with the mutex held. The net package does not allow WriteMsgUnix on a connected datagram socket.
+func (c *journaldConn) sendmsg(p, oob []byte) error {
+	raw, err := c.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sendErr error
+	err = raw.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendmsg(int(fd), p, oob, nil, 0)
+		return sendErr != syscall.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
+
+func journaldPayloadFile(payload []byte) (*os.File, error) {
+	if fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING); err == nil {
+		file := os.NewFile(uintptr(fd), "journal-entry")
+		if _, err := file.Write(payload); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if _, err := unix.FcntlInt(file.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return file, nil
+	}
+
+	dir := "/dev/shm"
+	if _, err := os.Stat(dir); err != nil {
+		dir = os.TempDir()
+	}
+	file, err := os.CreateTemp(dir, "journal-entry")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Write(payload); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,168 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+func parseJournaldPayload(t *testing.T, payload []byte) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for len(payload) > 0 {
+		i := bytes.IndexAny(payload, "=\n")
+		if i < 0 {
+			t.Fatalf("invalid payload %q", payload)
+		}
+		name := string(payload[:i])
+		if _, ok := fields[name]; ok {
+			t.Fatalf("field %s is written twice in %q", name, payload)
+		}
+		if payload[i] == '=' {
+			end := bytes.IndexByte(payload, '\n')
+			fields[name] = string(payload[i+1 : end])
+			payload = payload[end+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(payload[i+1 : i+9])
+		fields[name] = string(payload[i+9 : i+9+int(size)])
+		payload = payload[i+9+int(size)+1:]
+	}
+	return fields
+}
+
+func newJournaldListener(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, path
+}
+
+func readJournaldEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+	buf := make([]byte, 65536)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oobn == 0 {
+		return parseJournaldPayload(t, buf[:n])
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := os.NewFile(uintptr(fds[0]), "journal-entry")
+	defer file.Close()
+	payload, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseJournaldPayload(t, payload)
+}
+
+func TestJournald(t *testing.T) {
+	conn, path := newJournaldListener(t)
+	j, err := log.NewJournald(log.JournaldOptions{SocketPath: path, Identifier: "myapp", OnError: func(err error) { t.Error(err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	logger := log.NewWithFactory(log.NewJournaldWrapper(j))
+	logger.RegisterField(fieldComponent)
+
+	ctx := context.WithValue(context.Background(), fieldComponent, "api")
+	logger.Warn(ctx, "first line\nsecond line")
+
+	fields := readJournaldEntry(t, conn)
+	for k, v := range map[string]string{
+		"MESSAGE":           "first line\nsecond line",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "myapp",
+		"COMPONENT":         "api",
+		"CODE_FUNC":         "github.com/rockbears/log_test.TestJournald",
+	} {
+		if fields[k] != v {
+			t.Fatalf("want %s=%q, got %q in %v", k, v, fields[k], fields)
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "journald_linux_test.go") || fields["CODE_LINE"] == "" {
+		t.Fatalf("unexpected source fields %v", fields)
+	}
+}
+
+func TestJournaldReservedFields(t *testing.T) {
+	conn, path := newJournaldListener(t)
+	j, err := log.NewJournald(log.JournaldOptions{SocketPath: path, Identifier: "myapp", OnError: func(err error) { t.Error(err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	logger := log.NewWithFactory(log.NewJournaldWrapper(j))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	fields := []log.Field{"message", "priority", "syslog_identifier", "code_file"}
+	logger.RegisterField(fields...)
+
+	ctx := context.Background()
+	for _, f := range fields {
+		ctx = context.WithValue(ctx, f, "user "+string(f))
+	}
+	logger.Info(ctx, "this is info")
+
+	got := readJournaldEntry(t, conn)
+	for k, v := range map[string]string{
+		"MESSAGE":                 "this is info",
+		"PRIORITY":                "6",
+		"SYSLOG_IDENTIFIER":       "myapp",
+		"FIELD_MESSAGE":           "user message",
+		"FIELD_PRIORITY":          "user priority",
+		"FIELD_SYSLOG_IDENTIFIER": "user syslog_identifier",
+		"FIELD_CODE_FILE":         "user code_file",
+	} {
+		if got[k] != v {
+			t.Fatalf("want %s=%q, got %q in %v", k, v, got[k], got)
+		}
+	}
+	if _, ok := got["CODE_FILE"]; ok {
+		t.Fatalf("unexpected CODE_FILE in %v", got)
+	}
+}
+
+func TestJournaldLargeEntry(t *testing.T) {
+	conn, path := newJournaldListener(t)
+	j, err := log.NewJournald(log.JournaldOptions{SocketPath: path, OnError: func(err error) { t.Error(err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	logger := log.NewWithFactory(log.NewJournaldWrapper(j))
+
+	message := strings.Repeat("x", 4<<20)
+	logger.Info(context.Background(), message)
+
+	fields := readJournaldEntry(t, conn)
+	if fields["MESSAGE"] != message || fields["PRIORITY"] != "6" {
+		t.Fatalf("unexpected entry of %d bytes", len(fields["MESSAGE"]))
+	}
+}
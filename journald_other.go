@@ -0,0 +1,21 @@
+//go:build !linux
+
+package log
+
+import (
+	"errors"
+)
+
+type journaldConn struct{}
+
+func newJournaldConn(socketPath string) (*journaldConn, error) {
+	return nil, errors.New("journald is only supported on linux")
+}
+
+func (c *journaldConn) close() error {
+	return nil
+}
+
+func (c *journaldConn) send(payload []byte) error {
+	return errors.New("journald is only supported on linux")
+}
@@ -3,9 +3,9 @@ package log
 import (
 	"context"
 	"fmt"
-	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -18,20 +18,42 @@ const (
 	FieldStackTrace = Field("stack_trace")
 )
 
+type contextKey string
+
+const (
+	contextKeyIgnoreLevel = contextKey("ignore_level")
+	contextKeyFields      = contextKey("fields")
+	contextKeyFactory     = contextKey("factory")
+)
+
 var global *Logger
 var Factory = NewLogrusWrapper(logrus.StandardLogger())
 
 func init() {
 	global = New()
+	// Frames of this package are skipped anyway, it is kept for SetFramesToSkip(GetFramesToSkip() + n) users
 	global.callerFrameToSkip = 3
 }
 
 type Logger struct {
-	registeredFields  []Field
-	excludeRules      []ExcludeRule
-	factory           WrapperFactoryFunc
-	callerFrameToSkip int
-	mutex             sync.RWMutex
+	registeredFields []Field
+	// configFields are the registered fields added by ApplyConfig, the only ones a later config may remove
+	configFields []Field
+	excludeRules []ExcludeRule
+	// configExcludeRules are the exclude rules set by ApplyConfig, checked in addition to excludeRules
+	configExcludeRules []ExcludeRule
+	factory            WrapperFactoryFunc
+	callerFrameToSkip  int
+	level              Level
+	callerOptions      CallerOptions
+	stackTraceOptions  StackTraceOptions
+	recoverOptions     RecoverOptions
+	traceExtractors    []TraceExtractor
+	clock              func() time.Time
+	deterministic      bool
+	helpers            sync.Map
+	helperPrefixes     []string
+	mutex              sync.RWMutex
 }
 
 func New() *Logger {
@@ -39,7 +61,7 @@ func New() *Logger {
 }
 
 func NewWithFactory(factory WrapperFactoryFunc) *Logger {
-	logger := &Logger{factory: factory, callerFrameToSkip: 2}
+	logger := &Logger{factory: factory, callerFrameToSkip: 2, traceExtractors: DefaultTraceExtractors()}
 	logger.RegisterDefaultFields()
 	return logger
 }
@@ -58,11 +80,28 @@ func (l *Logger) SetFramesToSkip(s int) {
 	l.callerFrameToSkip = s
 }
 
+func (l *Logger) GetLevel() Level {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.level
+}
+
+// SetLevel sets the minimum level handled by the logger. The level of the underlying wrapper still applies.
+func (l *Logger) SetLevel(level Level) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.level = level
+}
+
 func (l *Logger) RegisterField(fields ...Field) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
 	for _, f := range fields {
+		// A field registered in code is kept whatever the config
+		l.configFields = removeField(l.configFields, f)
 		var exist bool
 		for _, existingF := range l.registeredFields {
 			if f == existingF {
@@ -86,6 +125,7 @@ func (l *Logger) UnregisterField(fields ...Field) {
 
 loop:
 	for _, f := range fields {
+		l.configFields = removeField(l.configFields, f)
 		for i, existingF := range l.registeredFields {
 			if f == existingF {
 				l.registeredFields = append(l.registeredFields[:i], l.registeredFields[i+1:]...)
@@ -108,13 +148,14 @@ func (l *Logger) GetRegisteredFields() []Field {
 	return fields
 }
 
+// GetExcludeRules returns the rules set with Skip, then the ones of the applied config.
 func (l *Logger) GetExcludeRules() []ExcludeRule {
 	l.mutex.RLock()
 	defer l.mutex.RUnlock()
 
-	excludeRules := make([]ExcludeRule, len(l.excludeRules))
-	copy(excludeRules, l.excludeRules)
-	return excludeRules
+	excludeRules := make([]ExcludeRule, 0, len(l.excludeRules)+len(l.configExcludeRules))
+	excludeRules = append(excludeRules, l.excludeRules...)
+	return append(excludeRules, l.configExcludeRules...)
 }
 
 func (l *Logger) RegisterDefaultFields() {
@@ -134,6 +175,20 @@ func (l *Logger) Skip(field Field, value interface{}) {
 	l.excludeRules = append(l.excludeRules, ExcludeRule{field, value})
 }
 
+// ContextWithFactory returns a context whose entries are written with factory, whatever the factory of the logger.
+// It lets parallel tests get their own output without changing the global Factory.
+func ContextWithFactory(ctx context.Context, factory WrapperFactoryFunc) context.Context {
+	return context.WithValue(ctx, contextKeyFactory, factory)
+}
+
+// GetFactory returns the factory of the logger, nil if it uses the global Factory.
+func (l *Logger) GetFactory() WrapperFactoryFunc {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.factory
+}
+
 func (l *Logger) SetFactory(factory WrapperFactoryFunc) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
@@ -142,102 +197,154 @@ func (l *Logger) SetFactory(factory WrapperFactoryFunc) {
 }
 
 func (l *Logger) Debug(ctx context.Context, format string, args ...interface{}) {
-	l.call(ctx, LevelDebug, format, args...)
+	l.call(ctx, 0, LevelDebug, format, args...)
 }
 
 func (l *Logger) Info(ctx context.Context, format string, args ...interface{}) {
-	l.call(ctx, LevelInfo, format, args...)
+	l.call(ctx, 0, LevelInfo, format, args...)
 }
 
 func (l *Logger) Warn(ctx context.Context, format string, args ...interface{}) {
-	l.call(ctx, LevelWarn, format, args...)
+	l.call(ctx, 0, LevelWarn, format, args...)
 }
 
 func (l *Logger) Error(ctx context.Context, format string, args ...interface{}) {
-	l.call(ctx, LevelError, format, args...)
+	l.call(ctx, 0, LevelError, format, args...)
 }
 
 func (l *Logger) Fatal(ctx context.Context, format string, args ...interface{}) {
-	l.call(ctx, LevelFatal, format, args...)
+	l.call(ctx, 0, LevelFatal, format, args...)
 }
 
 func (l *Logger) Panic(ctx context.Context, format string, args ...interface{}) {
-	l.call(ctx, LevelPanic, format, args...)
+	l.call(ctx, 0, LevelPanic, format, args...)
+}
+
+func (l *Logger) DebugDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	l.call(ctx, depth, LevelDebug, format, args...)
+}
+
+func (l *Logger) InfoDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	l.call(ctx, depth, LevelInfo, format, args...)
 }
 
-func (l *Logger) call(ctx context.Context, level Level, format string, args ...interface{}) {
-	var entry Wrapper
+func (l *Logger) WarnDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	l.call(ctx, depth, LevelWarn, format, args...)
+}
+
+func (l *Logger) ErrorDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	l.call(ctx, depth, LevelError, format, args...)
+}
 
+func (l *Logger) FatalDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	l.call(ctx, depth, LevelFatal, format, args...)
+}
+
+func (l *Logger) PanicDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	l.call(ctx, depth, LevelPanic, format, args...)
+}
+
+func (l *Logger) call(ctx context.Context, depth int, level Level, format string, args ...interface{}) {
 	l.mutex.RLock()
 
-	if l.factory == nil {
-		entry = Factory()
-	} else {
-		entry = l.factory()
+	factory, _ := ctx.Value(contextKeyFactory).(WrapperFactoryFunc)
+	if factory == nil {
+		factory = l.factory
+	}
+	if factory == nil {
+		factory = Factory
+	}
+
+	minLevel := l.level
+	callerFrameToSkip := l.callerFrameToSkip
+	callerOptions := l.effectiveCallerOptions()
+	helperPrefixes := l.copyHelperPrefixes()
+	traceExtractors := l.traceExtractors
+	clock := l.clock
+	deterministic := l.deterministic
+	registeredFields := make([]Field, len(l.registeredFields))
+	copy(registeredFields, l.registeredFields)
+	mExcludeRules := make(map[Field][]any, len(l.excludeRules)+len(l.configExcludeRules))
+	for _, rule := range l.excludeRules {
+		mExcludeRules[rule.Field] = append(mExcludeRules[rule.Field], rule.Value)
+	}
+	for _, rule := range l.configExcludeRules {
+		mExcludeRules[rule.Field] = append(mExcludeRules[rule.Field], rule.Value)
 	}
 
 	l.mutex.RUnlock()
 
-	if level < entry.GetLevel() {
-		return
+	// Held entries are only written if the buffer is flushed, the levels are not checked
+	buffer := bufferFromContext(ctx)
+	held := buffer.holds(level)
+
+	var wrapper Wrapper
+	if !held {
+		wrapper = factory()
+
+		if ignore, _ := ctx.Value(contextKeyIgnoreLevel).(bool); ignore {
+			minLevel = LevelDebug
+		}
+
+		if level < minLevel || level < wrapper.GetLevel() {
+			return
+		}
 	}
 
-	pc, file, line, ok := runtime.Caller(l.callerFrameToSkip)
-	if ok {
-		ctx = context.WithValue(ctx, FieldSourceFile, file)
-		ctx = context.WithValue(ctx, FieldSourceLine, line)
-		details := runtime.FuncForPC(pc)
-		if details != nil {
-			ctx = context.WithValue(ctx, FieldCaller, details.Name())
+	if frame, ok := l.callerFrame(callerFrameToSkip, depth, helperPrefixes); ok {
+		caller := newCaller(frame, callerOptions)
+		if callerOptions.Structured {
+			ctx = context.WithValue(ctx, FieldCaller, caller)
+		} else {
+			ctx = context.WithValue(ctx, FieldSourceFile, caller.File)
+			ctx = context.WithValue(ctx, FieldSourceLine, caller.Line)
+			ctx = context.WithValue(ctx, FieldCaller, caller.Function)
 		}
 	}
 
-	mExcludeRules := make(map[Field]any)
-	for _, rule := range l.GetExcludeRules() {
-		mExcludeRules[rule.Field] = rule.Value
+	ctx = contextWithTrace(ctx, traceExtractors)
+
+	fields := registeredFields
+	if extraFields, ok := ctx.Value(contextKeyFields).([]Field); ok {
+		fields = mergeFields(registeredFields, extraFields)
 	}
 
-	for _, k := range l.GetRegisteredFields() {
+	e := entry{level: level, time: entryTime(clock, deterministic), format: format, args: args}
+	for _, k := range fields {
 		v := ctx.Value(k)
 		if v != nil {
-			if excludeValue, has := mExcludeRules[k]; has {
-				if v == excludeValue {
+			for _, excludeValue := range mExcludeRules[k] {
+				if excludeValueMatches(v, excludeValue) {
 					return
 				}
 			}
-			entry.WithField(string(k), v)
+			e.fields = append(e.fields, entryField{key: k, value: v})
 		}
 	}
 
-	switch level {
-	case LevelInfo:
-		entry.Infof(format, args...)
-
-	case LevelWarn:
-		entry.Warnf(format, args...)
-
-	case LevelError:
-		entry.Errorf(format, args...)
-
-	case LevelFatal:
-		entry.Fatalf(format, args...)
-
-	case LevelPanic:
-		entry.Panicf(format, args...)
+	if deterministic {
+		sortEntryFields(e.fields)
+	}
 
-	default:
-		entry.Debugf(format, args...)
+	if held {
+		buffer.add(factory, e)
+		return
+	}
+	if buffer.triggers(level) {
+		buffer.flush(factory)
 	}
+
+	e.writeTo(wrapper)
 }
 
 func (l *Logger) ErrorWithStackTrace(ctx context.Context, err error) {
-	ctx = ContextWithStackTrace(ctx, err)
-	l.call(ctx, LevelError, err.Error())
+	ctx = l.contextWithStackTrace(ctx, err)
+	l.call(ctx, 0, LevelError, err.Error())
 }
 
 func (l *Logger) FieldValues(ctx context.Context) map[Field]interface{} {
 	res := make(map[Field]interface{}, 10)
-	for _, k := range l.registeredFields {
+	for _, k := range l.GetRegisteredFields() {
 		v := ctx.Value(k)
 		if v != nil {
 			res[k] = v
@@ -246,16 +353,58 @@ func (l *Logger) FieldValues(ctx context.Context) map[Field]interface{} {
 	return res
 }
 
+// contextWithFields adds values to the context. Their fields are emitted even if they are not registered.
+func contextWithFields(ctx context.Context, values map[Field]interface{}) context.Context {
+	extraFields, _ := ctx.Value(contextKeyFields).([]Field)
+	fields := make([]Field, len(extraFields), len(extraFields)+len(values))
+	copy(fields, extraFields)
+	for f, v := range values {
+		ctx = context.WithValue(ctx, f, v)
+		fields = append(fields, f)
+	}
+	return context.WithValue(ctx, contextKeyFields, fields)
+}
+
+func mergeFields(a, b []Field) []Field {
+	seen := make(map[Field]bool, len(a)+len(b))
+	fields := make([]Field, 0, len(a)+len(b))
+	for _, f := range append(append([]Field{}, a...), b...) {
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i] < fields[j]
+	})
+	return fields
+}
+
+// excludeValueMatches compares the numbers by value whatever their type, such as the int of a context and the
+// float64 or int64 of a JSON config.
+func excludeValueMatches(v, excludeValue any) bool {
+	if v == excludeValue {
+		return true
+	}
+	return isNumber(v) && isNumber(excludeValue) && fmt.Sprint(v) == fmt.Sprint(excludeValue)
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
 type StackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
+// ContextWithStackTrace walks the chain of err, including multi-errors, and adds the stack traces found to the context,
+// with the StackTraceOptions of the global logger.
 func ContextWithStackTrace(ctx context.Context, err error) context.Context {
-	errWithStracktrace, ok := err.(StackTracer)
-	if ok {
-		ctx = context.WithValue(ctx, FieldStackTrace, fmt.Sprintf("%+v", errWithStracktrace))
-	}
-	return ctx
+	return global.contextWithStackTrace(ctx, err)
 }
 
 func GetFramesToSkip() int {
@@ -266,6 +415,14 @@ func SetFramesToSkip(s int) {
 	global.SetFramesToSkip(s)
 }
 
+func GetLevel() Level {
+	return global.GetLevel()
+}
+
+func SetLevel(level Level) {
+	global.SetLevel(level)
+}
+
 func RegisterField(fields ...Field) {
 	global.RegisterField(fields...)
 }
@@ -286,6 +443,10 @@ func Skip(field Field, value interface{}) {
 	global.Skip(field, value)
 }
 
+func GetFactory() WrapperFactoryFunc {
+	return global.GetFactory()
+}
+
 func SetFactory(factory WrapperFactoryFunc) {
 	global.SetFactory(factory)
 }
@@ -314,6 +475,30 @@ func Panic(ctx context.Context, format string, args ...interface{}) {
 	global.Panic(ctx, format, args...)
 }
 
+func DebugDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	global.DebugDepth(ctx, depth, format, args...)
+}
+
+func InfoDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	global.InfoDepth(ctx, depth, format, args...)
+}
+
+func WarnDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	global.WarnDepth(ctx, depth, format, args...)
+}
+
+func ErrorDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	global.ErrorDepth(ctx, depth, format, args...)
+}
+
+func FatalDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	global.FatalDepth(ctx, depth, format, args...)
+}
+
+func PanicDepth(ctx context.Context, depth int, format string, args ...interface{}) {
+	global.PanicDepth(ctx, depth, format, args...)
+}
+
 func ErrorWithStackTrace(ctx context.Context, err error) {
 	global.ErrorWithStackTrace(ctx, err)
 }
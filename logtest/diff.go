@@ -0,0 +1,110 @@
+package logtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns the differences between the lines of want and got, with 3 lines of context.
+// It returns an empty string if they are equal.
+func unifiedDiff(wantName, gotName, want, got string) string {
+	a, b := splitLines(want), splitLines(got)
+
+	// Longest common subsequence, the files compared are small
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		}
+	}
+
+	const context = 3
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change and the end of its hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		end, unchanged := first, 0
+		for end < len(ops) && unchanged <= 2*context {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		end -= max(unchanged-context, 0)
+		begin := max(first-context, start)
+
+		// Line numbers of the hunk in both files
+		aLine, bLine := 1, 1
+		for _, op := range ops[:begin] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		var aCount, bCount int
+		for _, op := range ops[begin:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", wantName, gotName)
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, op := range ops[begin:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		start = end
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
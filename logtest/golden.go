@@ -0,0 +1,90 @@
+package logtest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rockbears/log"
+)
+
+// updateGolden tells whether the golden files should be written, when the tests define an -update flag set to true
+// or when the LOGTEST_UPDATE environment variable is 1. The flag is not defined by this package, to not conflict with the tests.
+func updateGolden() bool {
+	if f := flag.Lookup("update"); f != nil && f.Value.String() == "true" {
+		return true
+	}
+	return os.Getenv("LOGTEST_UPDATE") == "1"
+}
+
+// Golden returns the recorded entries in a deterministic form, one per line: the level, the fields sorted by name
+// and the message. Times are omitted and the source paths of the caller and stack trace fields are module-relative.
+func (r *Recorder) Golden() string {
+	var sb strings.Builder
+	for _, e := range r.Entries() {
+		keys := make([]string, 0, len(e.Fields))
+		for k := range e.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		sb.WriteString("[" + strings.ToUpper(e.Level.String()) + "] ")
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "[%s=%s]", k, goldenValue(k, e.Fields[k]))
+		}
+		sb.WriteString(" " + e.Message + "\n")
+	}
+	return sb.String()
+}
+
+func goldenValue(key string, value interface{}) string {
+	switch x := value.(type) {
+	case string:
+		if key == string(log.FieldSourceFile) {
+			return log.ModuleRelativePath(x)
+		}
+		return x
+	case log.Caller:
+		x.File = log.ModuleRelativePath(x.File)
+		return x.String()
+	case log.StackTrace:
+		frames := make(log.StackTrace, len(x))
+		for i, f := range x {
+			f.File = log.ModuleRelativePath(f.File)
+			frames[i] = f
+		}
+		return frames.String()
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// AssertGolden compares the golden form of the entries recorded by r with testdata/<name>.golden.
+// Run the tests with -update, if they define this flag, or with LOGTEST_UPDATE=1 to write the file instead.
+func AssertGolden(t testing.TB, r *Recorder, name string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	got := r.Golden()
+
+	if updateGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read golden file, run the test with -update or LOGTEST_UPDATE=1 to create it: %v", err)
+	}
+	if diff := unifiedDiff(path, "got", string(want), got); diff != "" {
+		t.Errorf("log entries differ from %s, run the test with -update or LOGTEST_UPDATE=1 to update it:\n%s", path, diff)
+	}
+}
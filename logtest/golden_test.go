@@ -0,0 +1,88 @@
+package logtest_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rockbears/log"
+	"github.com/rockbears/log/logtest"
+)
+
+// update is defined by the tests, as usual with golden files, and is used by logtest.AssertGolden
+var update = flag.Bool("update", false, "update the golden files")
+
+func logGoldenEntries(ctx context.Context, logger *log.Logger, asset string) {
+	ctx = context.WithValue(ctx, fieldComponent, "api")
+	logger.Info(ctx, "loading %s", asset)
+	logger.Warn(context.WithValue(ctx, log.Field("asset"), asset), "unable to load %s", asset)
+}
+
+func TestAssertGolden(t *testing.T) {
+	rec := logtest.NewRecorder()
+	logger := log.NewWithFactory(rec.Factory())
+	logger.RegisterField(fieldComponent, log.Field("asset"))
+	logger.UnregisterField(log.FieldSourceLine)
+
+	logGoldenEntries(context.Background(), logger, "foo")
+	logtest.AssertGolden(t, rec, "entries")
+}
+
+func TestAssertGoldenDiff(t *testing.T) {
+	if *update || os.Getenv("LOGTEST_UPDATE") == "1" {
+		t.Skip("the golden file would be overwritten by a different output")
+	}
+	rec := logtest.NewRecorder()
+	logger := log.NewWithFactory(rec.Factory())
+	logger.RegisterField(fieldComponent, log.Field("asset"))
+	logger.UnregisterField(log.FieldSourceLine)
+
+	logGoldenEntries(context.Background(), logger, "bar")
+	ft := &fakeT{TB: t}
+	logtest.AssertGolden(ft, rec, "entries")
+	if ft.errors != 1 {
+		t.Fatalf("want 1 failure, got %d", ft.errors)
+	}
+	for _, want := range []string{
+		"--- testdata/entries.golden\n+++ got\n@@ -1,2 +1,2 @@\n",
+		"\n-[INFO] [caller=github.com/rockbears/log/logtest_test.logGoldenEntries][component=api][source_file=logtest/golden_test.go] loading foo\n",
+		"\n+[INFO] [caller=github.com/rockbears/log/logtest_test.logGoldenEntries][component=api][source_file=logtest/golden_test.go] loading bar\n",
+	} {
+		if !strings.Contains(ft.messages[0], want) {
+			t.Fatalf("diff should contain %q, got:\n%s", want, ft.messages[0])
+		}
+	}
+}
+
+func TestAssertGoldenUpdate(t *testing.T) {
+	rec := logtest.NewRecorder()
+	logger := log.NewWithFactory(rec.Factory())
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.Info(context.Background(), "this is info")
+
+	for name, enable := range map[string]func(t *testing.T){
+		"flag": func(t *testing.T) {
+			*update = true
+			t.Cleanup(func() { *update = false })
+		},
+		"env": func(t *testing.T) {
+			t.Setenv("LOGTEST_UPDATE", "1")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			enable(t)
+			logtest.AssertGolden(t, rec, "update")
+			got, err := os.ReadFile(filepath.Join("testdata", "update.golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "[INFO]  this is info\n" {
+				t.Fatalf("unexpected golden file %q", got)
+			}
+		})
+	}
+}
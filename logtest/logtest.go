@@ -0,0 +1,194 @@
+// Package logtest records the entries logged by the code under test and provides assertions on them.
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+type Entry struct {
+	Time    time.Time
+	Level   log.Level
+	Message string
+	Fields  map[string]interface{}
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("[%s] %v %s", e.Level, e.Fields, e.Message)
+}
+
+// Recorder keeps the entries logged through its factory. It is safe for concurrent use.
+type Recorder struct {
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Factory returns a factory recording every entry, whatever its level. Fatal and Panic entries are recorded
+// without exiting or panicking.
+func (r *Recorder) Factory() log.WrapperFactoryFunc {
+	return func() log.Wrapper {
+		return &recorderWrapper{recorder: r}
+	}
+}
+
+func (r *Recorder) Entries() []Entry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Entry(nil), r.entries...)
+}
+
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries = nil
+}
+
+// NewContext returns a context whose entries are recorded by r whatever the factory of the logger.
+func (r *Recorder) NewContext(ctx context.Context) context.Context {
+	return log.ContextWithFactory(ctx, r.Factory())
+}
+
+func (r *Recorder) add(e Entry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries = append(r.entries, e)
+}
+
+// Capture records the entries logged by logger while fn runs. The factory of the logger is restored afterwards,
+// the global Factory is left untouched.
+func Capture(logger *log.Logger, fn func()) *Recorder {
+	r := NewRecorder()
+	previous := logger.GetFactory()
+	logger.SetFactory(r.Factory())
+	defer logger.SetFactory(previous)
+
+	fn()
+	return r
+}
+
+// NewContext returns the context of the test, whose entries are written to t whatever the factory of the logger.
+// Unlike setting log.Factory, it is safe with parallel tests.
+func NewContext(t testing.TB) context.Context {
+	return log.ContextWithFactory(t.Context(), log.NewTestingWrapper(t))
+}
+
+// Match returns the recorded entries at the given level whose message contains msgSubstring
+// and which have all the given fields. Field values are compared with reflect.DeepEqual, then by their %v format.
+func (r *Recorder) Match(level log.Level, msgSubstring string, fields map[log.Field]interface{}) []Entry {
+	var matches []Entry
+	for _, e := range r.Entries() {
+		if e.Level == level && strings.Contains(e.Message, msgSubstring) && hasFields(e, fields) {
+			matches = append(matches, e)
+		}
+	}
+	return matches
+}
+
+func hasFields(e Entry, fields map[log.Field]interface{}) bool {
+	for k, want := range fields {
+		got, ok := e.Fields[string(k)]
+		if !ok {
+			return false
+		}
+		if !reflect.DeepEqual(got, want) && fmt.Sprintf("%v", got) != fmt.Sprintf("%v", want) {
+			return false
+		}
+	}
+	return true
+}
+
+func AssertLogged(t testing.TB, r *Recorder, level log.Level, msgSubstring string, fields map[log.Field]interface{}) {
+	t.Helper()
+	if len(r.Match(level, msgSubstring, fields)) == 0 {
+		t.Errorf("no %s entry containing %q with fields %v was logged, got:\n%s", level, msgSubstring, fields, formatEntries(r.Entries()))
+	}
+}
+
+func AssertNotLogged(t testing.TB, r *Recorder, level log.Level, msgSubstring string, fields map[log.Field]interface{}) {
+	t.Helper()
+	if matches := r.Match(level, msgSubstring, fields); len(matches) > 0 {
+		t.Errorf("unexpected %s entry containing %q with fields %v:\n%s", level, msgSubstring, fields, formatEntries(matches))
+	}
+}
+
+func formatEntries(entries []Entry) string {
+	if len(entries) == 0 {
+		return "  (none)"
+	}
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, "  "+e.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type recorderWrapper struct {
+	recorder *Recorder
+	fields   map[string]interface{}
+	time     time.Time
+}
+
+func (w *recorderWrapper) GetLevel() log.Level {
+	return log.LevelDebug
+}
+
+func (w *recorderWrapper) WithField(key string, value interface{}) {
+	if w.fields == nil {
+		w.fields = map[string]interface{}{}
+	}
+	w.fields[key] = value
+}
+
+func (w *recorderWrapper) WithTime(t time.Time) {
+	w.time = t
+}
+
+func (w *recorderWrapper) add(level log.Level, format string, args ...interface{}) {
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	t := w.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	w.recorder.add(Entry{Time: t, Level: level, Message: msg, Fields: w.fields})
+}
+
+func (w *recorderWrapper) Debugf(format string, args ...interface{}) {
+	w.add(log.LevelDebug, format, args...)
+}
+
+func (w *recorderWrapper) Infof(format string, args ...interface{}) {
+	w.add(log.LevelInfo, format, args...)
+}
+
+func (w *recorderWrapper) Warnf(format string, args ...interface{}) {
+	w.add(log.LevelWarn, format, args...)
+}
+
+func (w *recorderWrapper) Fatalf(format string, args ...interface{}) {
+	w.add(log.LevelFatal, format, args...)
+}
+
+func (w *recorderWrapper) Errorf(format string, args ...interface{}) {
+	w.add(log.LevelError, format, args...)
+}
+
+func (w *recorderWrapper) Panicf(format string, args ...interface{}) {
+	w.add(log.LevelPanic, format, args...)
+}
@@ -0,0 +1,122 @@
+package logtest_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rockbears/log"
+	"github.com/rockbears/log/logtest"
+)
+
+const fieldComponent = log.Field("component")
+
+func TestCapture(t *testing.T) {
+	logger := log.New()
+	logger.RegisterField(fieldComponent)
+
+	ctx := context.WithValue(context.Background(), fieldComponent, "api")
+	rec := logtest.Capture(logger, func() {
+		logger.Warn(ctx, "unable to load %s", "foo")
+		logger.Debug(context.Background(), "loading")
+	})
+
+	if logger.GetFactory() != nil {
+		t.Fatal("the factory of the logger should be restored")
+	}
+	if _, ok := log.Factory().(*log.LogrusWrapper); !ok {
+		t.Fatal("the global factory should be left untouched")
+	}
+
+	logtest.AssertLogged(t, rec, log.LevelWarn, "unable to load", map[log.Field]interface{}{fieldComponent: "api"})
+	logtest.AssertLogged(t, rec, log.LevelDebug, "loading", nil)
+	logtest.AssertNotLogged(t, rec, log.LevelError, "", nil)
+	logtest.AssertNotLogged(t, rec, log.LevelWarn, "unable", map[log.Field]interface{}{fieldComponent: "db"})
+
+	if n := len(rec.Entries()); n != 2 {
+		t.Fatalf("want 2 entries, got %d", n)
+	}
+	rec.Reset()
+	if n := len(rec.Entries()); n != 0 {
+		t.Fatalf("want no entry after reset, got %d", n)
+	}
+}
+
+func TestAssertLoggedFailure(t *testing.T) {
+	rec := logtest.NewRecorder()
+	logger := log.NewWithFactory(rec.Factory())
+	logger.Info(context.Background(), "this is info")
+
+	ft := &fakeT{TB: t}
+	logtest.AssertLogged(ft, rec, log.LevelWarn, "this is info", nil)
+	logtest.AssertNotLogged(ft, rec, log.LevelInfo, "info", nil)
+	if ft.errors != 2 {
+		t.Fatalf("want 2 failures, got %d", ft.errors)
+	}
+}
+
+type fakeT struct {
+	testing.TB
+	errors   int
+	messages []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors++
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+}
+
+func TestNewContextParallel(t *testing.T) {
+	for _, name := range []string{"a", "b", "c"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rec := logtest.NewRecorder()
+			ctx := rec.NewContext(context.Background())
+			for i := 0; i < 10; i++ {
+				log.Info(ctx, "entry of %s", name)
+			}
+			entries := rec.Entries()
+			if len(entries) != 10 {
+				t.Fatalf("want 10 entries, got %d", len(entries))
+			}
+			for _, e := range entries {
+				if e.Message != "entry of "+name {
+					t.Fatalf("unexpected entry %v", e)
+				}
+			}
+		})
+	}
+}
+
+// logT records the lines logged to the test.
+type logT struct {
+	testing.TB
+	lines []string
+}
+
+func (l *logT) Helper() {}
+
+func (l *logT) Log(args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprint(args...))
+}
+
+func TestNewContext(t *testing.T) {
+	rec := logtest.NewRecorder()
+	factory := log.Factory
+	log.Factory = rec.Factory()
+	defer func() { log.Factory = factory }()
+
+	lt := &logT{TB: t}
+	ctx := logtest.NewContext(lt)
+	log.Info(ctx, "written to the test output")
+
+	if len(lt.lines) != 1 || !strings.Contains(lt.lines[0], "written to the test output") {
+		t.Fatalf("want the entry in the test output, got %q", lt.lines)
+	}
+	if entries := rec.Entries(); len(entries) != 0 {
+		t.Fatalf("the global factory should not receive the entry, got %v", entries)
+	}
+}
@@ -0,0 +1,278 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Grafana Loki push exporter */
+
+type LokiOptions struct {
+	// URL is the push endpoint, such as http://localhost:3100/loki/api/v1/push
+	URL     string
+	Headers map[string]string
+	// TenantID, if set, is sent as the X-Scope-OrgID header
+	TenantID string
+	// Labels are added to every stream. LabelFields are the fields turned into stream labels, they should have few distinct values.
+	// The level is always a label.
+	Labels      map[string]string
+	LabelFields []Field
+	// StructuredMetadata sends the other fields as structured metadata, instead of appending them to the line as key=value pairs
+	StructuredMetadata bool
+	Level              Level
+
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxQueueSize  int
+	// MaxRetries defaults to 5, a negative value disables retries
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	HTTPClient     *http.Client
+	// OnDrop is called when entries are dropped, because the queue is full or because their batch could not be pushed
+	OnDrop func(err error, count int)
+}
+
+type lokiEntry struct {
+	labels   map[string]string
+	time     time.Time
+	line     string
+	metadata map[string]string
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][]interface{}   `json:"values"`
+}
+
+// LokiExporter pushes entries to Loki by batches, grouped by stream.
+type LokiExporter struct {
+	opts        LokiOptions
+	labelFields map[string]bool
+	batcher     *batcher[lokiEntry]
+}
+
+func NewLokiExporter(opts LokiOptions) *LokiExporter {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	// Loki rejects the whole push for an invalid label name
+	labels := make(map[string]string, len(opts.Labels))
+	for k, v := range opts.Labels {
+		labels[lokiLabelName(k)] = v
+	}
+	opts.Labels = labels
+	e := &LokiExporter{opts: opts, labelFields: map[string]bool{}}
+	for _, f := range opts.LabelFields {
+		e.labelFields[string(f)] = true
+	}
+	e.batcher = newBatcher(batcherOptions{
+		BatchSize:     opts.BatchSize,
+		FlushInterval: opts.FlushInterval,
+		MaxQueueSize:  opts.MaxQueueSize,
+		OnDrop:        opts.OnDrop,
+	}, e.push)
+	return e
+}
+
+// Flush pushes the queued entries.
+func (e *LokiExporter) Flush(ctx context.Context) error {
+	return e.batcher.Flush(ctx)
+}
+
+// Shutdown pushes the queued entries and stops the exporter.
+func (e *LokiExporter) Shutdown(ctx context.Context) error {
+	return e.batcher.Shutdown(ctx)
+}
+
+// Dropped returns the number of entries dropped so far.
+func (e *LokiExporter) Dropped() uint64 {
+	return e.batcher.Dropped()
+}
+
+// push sends the entries grouped by stream, in the order of their first entry, as a gzipped JSON payload.
+func (e *LokiExporter) push(ctx context.Context, entries []lokiEntry) error {
+	var streams []*lokiStream
+	byLabels := map[string]*lokiStream{}
+	for _, entry := range entries {
+		key := lokiStreamKey(entry.labels)
+		s, ok := byLabels[key]
+		if !ok {
+			s = &lokiStream{Stream: entry.labels}
+			byLabels[key] = s
+			streams = append(streams, s)
+		}
+		value := []interface{}{strconv.FormatInt(entry.time.UnixNano(), 10), entry.line}
+		if len(entry.metadata) > 0 {
+			value = append(value, entry.metadata)
+		}
+		s.Values = append(s.Values, value)
+	}
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(map[string]interface{}{"streams": streams}); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{"gzip"}}
+	if e.opts.TenantID != "" {
+		header.Set("X-Scope-OrgID", e.opts.TenantID)
+	}
+	for k, v := range e.opts.Headers {
+		header.Set(k, v)
+	}
+	retryOpts := retryOptions{MaxRetries: e.opts.MaxRetries, InitialBackoff: e.opts.InitialBackoff, MaxBackoff: e.opts.MaxBackoff}
+	return postWithRetry(ctx, e.opts.HTTPClient, e.opts.URL, header, body.Bytes(), retryOpts, func(status int) bool {
+		return status == http.StatusTooManyRequests || status >= 500
+	})
+}
+
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s=%q,", k, labels[k])
+	}
+	return sb.String()
+}
+
+var lokiInvalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// lokiLabelName replaces the characters not allowed in label names by underscores. The level label is reserved,
+// a label of the same name is prefixed by field_.
+func lokiLabelName(key string) string {
+	name := lokiInvalidLabelChars.ReplaceAllString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	if name == "level" {
+		name = "field_level"
+	}
+	return name
+}
+
+// lokiLineValue quotes the values holding spaces, quotes or equal signs, such as in logfmt.
+func lokiLineValue(value interface{}) string {
+	s := fmt.Sprintf("%v", value)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func NewLokiWrapper(exporter *LokiExporter) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &LokiWrapper{exporter: exporter}
+	}
+}
+
+type LokiWrapper struct {
+	exporter *LokiExporter
+	labels   map[string]string
+	fields   []entryField
+	time     time.Time
+}
+
+func (l *LokiWrapper) GetLevel() Level {
+	return l.exporter.opts.Level
+}
+
+// WithField adds the field as a stream label if it is one of the LabelFields, to the line or the structured metadata otherwise.
+func (l *LokiWrapper) WithField(key string, value interface{}) {
+	if l.exporter.labelFields[key] {
+		if l.labels == nil {
+			l.labels = map[string]string{}
+		}
+		l.labels[lokiLabelName(key)] = fmt.Sprintf("%v", value)
+		return
+	}
+	l.fields = append(l.fields, entryField{key: Field(key), value: value})
+}
+
+func (l *LokiWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *LokiWrapper) add(level Level, format string, args ...interface{}) {
+	e := lokiEntry{time: l.time, labels: map[string]string{}}
+	if e.time.IsZero() {
+		e.time = time.Now()
+	}
+	for k, v := range l.exporter.opts.Labels {
+		e.labels[k] = v
+	}
+	for k, v := range l.labels {
+		e.labels[k] = v
+	}
+	e.labels["level"] = level.String()
+
+	var sb strings.Builder
+	sb.WriteString(getFormatedMsg(format, args...))
+	for _, f := range l.fields {
+		if l.exporter.opts.StructuredMetadata {
+			if e.metadata == nil {
+				e.metadata = map[string]string{}
+			}
+			e.metadata[lokiLabelName(string(f.key))] = fmt.Sprintf("%v", f.value)
+			continue
+		}
+		fmt.Fprintf(&sb, " %s=%s", f.key, lokiLineValue(f.value))
+	}
+	e.line = sb.String()
+	l.exporter.batcher.Add(e)
+}
+
+func (l *LokiWrapper) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = l.exporter.Flush(ctx)
+}
+
+func (l *LokiWrapper) Debugf(format string, args ...interface{}) {
+	l.add(LevelDebug, format, args...)
+}
+
+func (l *LokiWrapper) Infof(format string, args ...interface{}) {
+	l.add(LevelInfo, format, args...)
+}
+
+func (l *LokiWrapper) Warnf(format string, args ...interface{}) {
+	l.add(LevelWarn, format, args...)
+}
+
+func (l *LokiWrapper) Fatalf(format string, args ...interface{}) {
+	l.add(LevelFatal, format, args...)
+	l.flush()
+	os.Exit(1)
+}
+
+func (l *LokiWrapper) Errorf(format string, args ...interface{}) {
+	l.add(LevelError, format, args...)
+}
+
+func (l *LokiWrapper) Panicf(format string, args ...interface{}) {
+	l.add(LevelPanic, format, args...)
+	l.flush()
+	panic(getFormatedMsg(format, args...))
+}
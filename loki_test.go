@@ -0,0 +1,186 @@
+package log_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+type lokiRequest struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]interface{}   `json:"values"`
+	} `json:"streams"`
+}
+
+func TestLokiExporter(t *testing.T) {
+	var mutex sync.Mutex
+	var calls int
+	var requests []lokiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("X-Scope-OrgID") != "tenant" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var req lokiRequest
+		if err := json.NewDecoder(zr).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	exporter := log.NewLokiExporter(log.LokiOptions{
+		URL:            srv.URL + "/loki/api/v1/push",
+		TenantID:       "tenant",
+		Labels:         map[string]string{"env": "test"},
+		LabelFields:    []log.Field{fieldComponent},
+		Level:          log.LevelInfo,
+		FlushInterval:  time.Hour,
+		InitialBackoff: time.Millisecond,
+	})
+	logger := log.NewWithFactory(log.NewLokiWrapper(exporter))
+	logger.RegisterField(fieldComponent, fieldAsset)
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	now := time.Unix(1700000000, 42)
+	logger.SetClock(func() time.Time { return now })
+
+	ctx := context.WithValue(context.Background(), fieldComponent, "api")
+	logger.Debug(ctx, "this log should not be pushed")
+	logger.Info(ctx, "first")
+	logger.Info(context.WithValue(ctx, fieldAsset, "my asset"), "second")
+	logger.Info(context.Background(), "third")
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if calls != 2 || len(requests) != 1 {
+		t.Fatalf("want one retried request, got %d calls", calls)
+	}
+	streams := requests[0].Streams
+	if len(streams) != 2 {
+		t.Fatalf("want 2 streams, got %+v", streams)
+	}
+	if s := streams[0].Stream; len(s) != 3 || s["env"] != "test" || s["component"] != "api" || s["level"] != "info" {
+		t.Fatalf("unexpected labels %v", s)
+	}
+	if v := streams[0].Values; len(v) != 2 || v[0][0] != "1700000000000000042" || v[0][1] != "first" || v[1][1] != `second asset="my asset"` {
+		t.Fatalf("unexpected values %v", v)
+	}
+	if s := streams[1].Stream; len(s) != 2 || s["env"] != "test" || s["level"] != "info" {
+		t.Fatalf("unexpected labels %v", s)
+	}
+	if v := streams[1].Values; len(v) != 1 || v[0][1] != "third" {
+		t.Fatalf("unexpected values %v", v)
+	}
+}
+
+func TestLokiExporterStructuredMetadata(t *testing.T) {
+	var request lokiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := json.NewDecoder(zr).Decode(&request); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	exporter := log.NewLokiExporter(log.LokiOptions{URL: srv.URL, StructuredMetadata: true, FlushInterval: time.Hour})
+	logger := log.NewWithFactory(log.NewLokiWrapper(exporter))
+	logger.RegisterField(fieldAsset)
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.Warn(context.WithValue(context.Background(), fieldAsset, "my asset"), "this is warn")
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	v := request.Streams[0].Values[0]
+	if len(v) != 3 || v[1] != "this is warn" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	if metadata, _ := v[2].(map[string]interface{}); len(metadata) != 1 || metadata["asset"] != "my asset" {
+		t.Fatalf("unexpected structured metadata %v", v[2])
+	}
+}
+
+func TestLokiExporterLabelNames(t *testing.T) {
+	var request lokiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := json.NewDecoder(zr).Decode(&request); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	exporter := log.NewLokiExporter(log.LokiOptions{
+		URL:           srv.URL,
+		Labels:        map[string]string{"my-env": "test"},
+		LabelFields:   []log.Field{"level"},
+		FlushInterval: time.Hour,
+	})
+	logger := log.NewWithFactory(log.NewLokiWrapper(exporter))
+	logger.RegisterField("level")
+	logger.Error(context.WithValue(context.Background(), log.Field("level"), "user level"), "this is error")
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := request.Streams[0].Stream; len(s) != 3 || s["my_env"] != "test" || s["level"] != "error" || s["field_level"] != "user level" {
+		t.Fatalf("unexpected labels %v", s)
+	}
+}
+
+func TestLokiExporterDrop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var dropped int
+	exporter := log.NewLokiExporter(log.LokiOptions{
+		URL:           srv.URL,
+		FlushInterval: time.Hour,
+		OnDrop:        func(err error, count int) { dropped += count },
+	})
+	logger := log.NewWithFactory(log.NewLokiWrapper(exporter))
+	logger.Info(context.Background(), "first")
+	logger.Info(context.Background(), "second")
+
+	if err := exporter.Flush(context.Background()); err == nil {
+		t.Fatal("want error")
+	}
+	if dropped != 2 || exporter.Dropped() != 2 {
+		t.Fatalf("want 2 dropped entries, got %d", dropped)
+	}
+}
@@ -0,0 +1,264 @@
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// msgpackEncoder appends MessagePack values to a buffer, see https://github.com/msgpack/msgpack/blob/master/spec.md
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) appendNil() {
+	e.buf = append(e.buf, 0xc0)
+}
+
+func (e *msgpackEncoder) appendBool(b bool) {
+	if b {
+		e.buf = append(e.buf, 0xc3)
+	} else {
+		e.buf = append(e.buf, 0xc2)
+	}
+}
+
+func (e *msgpackEncoder) appendInt(i int64) {
+	switch {
+	case i >= 0:
+		e.appendUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(i))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) appendUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(u))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), u)
+	}
+}
+
+func (e *msgpackEncoder) appendFloat(f float64) {
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(f))
+}
+
+func (e *msgpackEncoder) appendString(s string) {
+	switch n := len(s); {
+	case n <= 31:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xda), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdb), uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) appendBinary(b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xc5), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xc6), uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *msgpackEncoder) appendArrayHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xdc), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdd), uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) appendMapHeader(n int) {
+	switch {
+	case n <= 15:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xde), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xdf), uint32(n))
+	}
+}
+
+// appendEventTime appends the EventTime extension of the Forward protocol, a fixext 8 of type 0.
+func (e *msgpackEncoder) appendEventTime(t time.Time) {
+	e.buf = append(e.buf, 0xd7, 0x00)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Unix()))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(t.Nanosecond()))
+}
+
+func (e *msgpackEncoder) appendStringMap(m map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.appendMapHeader(len(keys))
+	for _, k := range keys {
+		e.appendString(k)
+		e.appendValue(m[k])
+	}
+}
+
+func (e *msgpackEncoder) appendValue(value interface{}) {
+	switch x := value.(type) {
+	case nil:
+		e.appendNil()
+	case bool:
+		e.appendBool(x)
+	case string:
+		e.appendString(x)
+	case []byte:
+		e.appendBinary(x)
+	case int:
+		e.appendInt(int64(x))
+	case int8:
+		e.appendInt(int64(x))
+	case int16:
+		e.appendInt(int64(x))
+	case int32:
+		e.appendInt(int64(x))
+	case int64:
+		e.appendInt(x)
+	case uint:
+		e.appendUint(uint64(x))
+	case uint8:
+		e.appendUint(uint64(x))
+	case uint16:
+		e.appendUint(uint64(x))
+	case uint32:
+		e.appendUint(uint64(x))
+	case uint64:
+		e.appendUint(x)
+	case float32:
+		e.appendFloat(float64(x))
+	case float64:
+		e.appendFloat(x)
+	case []string:
+		e.appendArrayHeader(len(x))
+		for _, s := range x {
+			e.appendString(s)
+		}
+	case map[string]interface{}:
+		e.appendStringMap(x)
+	case Caller:
+		e.appendStringMap(map[string]interface{}{"function": x.Function, "file": x.File, "line": x.Line})
+	case StackTrace:
+		e.appendArrayHeader(len(x))
+		for _, f := range x {
+			e.appendStringMap(map[string]interface{}{"function": f.Function, "file": f.File, "line": f.Line})
+		}
+	case error:
+		e.appendString(x.Error())
+	default:
+		e.appendString(fmt.Sprintf("%v", value))
+	}
+}
+
+// readMsgpackStringMap reads a map whose keys and values are strings, such as the ack responses of the Forward protocol.
+func readMsgpackStringMap(r *bufio.Reader) (map[string]string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	switch {
+	case b&0xf0 == 0x80:
+		n = int(b & 0x0f)
+	case b == 0xde:
+		var size uint16
+		err = binary.Read(r, binary.BigEndian, &size)
+		n = int(size)
+	case b == 0xdf:
+		var size uint32
+		err = binary.Read(r, binary.BigEndian, &size)
+		n = int(size)
+	default:
+		return nil, fmt.Errorf("unexpected msgpack type 0x%02x, want a map", b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k, err := readMsgpackString(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgpackString(r)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func readMsgpackString(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	var n int
+	switch {
+	case b&0xe0 == 0xa0:
+		n = int(b & 0x1f)
+	case b == 0xd9 || b == 0xc4:
+		var size uint8
+		size, err = r.ReadByte()
+		n = int(size)
+	case b == 0xda || b == 0xc5:
+		var size uint16
+		err = binary.Read(r, binary.BigEndian, &size)
+		n = int(size)
+	case b == 0xdb || b == 0xc6:
+		var size uint32
+		err = binary.Read(r, binary.BigEndian, &size)
+		n = int(size)
+	default:
+		return "", fmt.Errorf("unexpected msgpack type 0x%02x, want a string", b)
+	}
+	if err != nil {
+		return "", err
+	}
+	if n > 1<<20 {
+		return "", errors.New("msgpack string too large")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
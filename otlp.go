@@ -0,0 +1,328 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* OTLP/HTTP JSON exporter */
+
+type OTLPOptions struct {
+	// Endpoint is the full URL of the logs endpoint of the collector, such as http://localhost:4318/v1/logs
+	Endpoint string
+	Headers  map[string]string
+	// Resource attributes, such as service.name
+	Resource map[string]interface{}
+	Level    Level
+
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxQueueSize  int
+	// MaxRetries defaults to 5, a negative value disables retries
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	HTTPClient     *http.Client
+	// OnDrop is called when log records are dropped, because the queue is full or because they could not be exported
+	OnDrop func(err error, count int)
+}
+
+type OTLPExporter struct {
+	opts     OTLPOptions
+	resource otlpResource
+	batcher  *batcher[otlpLogRecord]
+}
+
+func NewOTLPExporter(opts OTLPOptions) *OTLPExporter {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	e := &OTLPExporter{opts: opts}
+	keys := make([]string, 0, len(opts.Resource))
+	for k := range opts.Resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.resource.Attributes = append(e.resource.Attributes, otlpKeyValue{Key: k, Value: newOTLPAnyValue(opts.Resource[k])})
+	}
+	e.batcher = newBatcher(batcherOptions{
+		BatchSize:     opts.BatchSize,
+		FlushInterval: opts.FlushInterval,
+		MaxQueueSize:  opts.MaxQueueSize,
+		OnDrop:        opts.OnDrop,
+	}, e.export)
+	return e
+}
+
+// Flush exports the queued log records.
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	return e.batcher.Flush(ctx)
+}
+
+// Shutdown exports the queued log records and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	return e.batcher.Shutdown(ctx)
+}
+
+// Dropped returns the number of log records dropped so far.
+func (e *OTLPExporter) Dropped() uint64 {
+	return e.batcher.Dropped()
+}
+
+func (e *OTLPExporter) export(ctx context.Context, records []otlpLogRecord) error {
+	body, err := json.Marshal(otlpExportLogsServiceRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: packagePath},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	for k, v := range e.opts.Headers {
+		header.Set(k, v)
+	}
+	retryOpts := retryOptions{MaxRetries: e.opts.MaxRetries, InitialBackoff: e.opts.InitialBackoff, MaxBackoff: e.opts.MaxBackoff}
+	return postWithRetry(ctx, e.opts.HTTPClient, e.opts.Endpoint, header, body, retryOpts, func(status int) bool {
+		return status == http.StatusTooManyRequests || status == http.StatusBadGateway ||
+			status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+	})
+}
+
+func NewOTLPWrapper(exporter *OTLPExporter) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &OTLPWrapper{exporter: exporter}
+	}
+}
+
+type OTLPWrapper struct {
+	exporter *OTLPExporter
+	record   otlpLogRecord
+	time     time.Time
+}
+
+func (l *OTLPWrapper) GetLevel() Level {
+	return l.exporter.opts.Level
+}
+
+// WithField adds the field as an attribute of the log record, except the trace fields which are set on the record itself.
+func (l *OTLPWrapper) WithField(key string, value interface{}) {
+	s, isString := value.(string)
+	switch {
+	case key == string(FieldTraceID) && isString:
+		l.record.TraceID = s
+	case key == string(FieldSpanID) && isString:
+		l.record.SpanID = s
+	case key == string(FieldTraceFlags) && isString:
+		if flags, err := strconv.ParseUint(s, 16, 8); err == nil {
+			l.record.Flags = uint32(flags)
+		}
+	default:
+		l.record.Attributes = append(l.record.Attributes, otlpKeyValue{Key: key, Value: newOTLPAnyValue(value)})
+	}
+}
+
+func (l *OTLPWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *OTLPWrapper) emit(level Level, format string, args ...interface{}) {
+	now := time.Now()
+	t := l.time
+	if t.IsZero() {
+		t = now
+	}
+	msg := getFormatedMsg(format, args...)
+	l.record.TimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+	l.record.ObservedTimeUnixNano = strconv.FormatInt(now.UnixNano(), 10)
+	l.record.SeverityNumber = otlpSeverityNumbers[level]
+	l.record.SeverityText = strings.ToUpper(level.String())
+	l.record.Body = otlpAnyValue{StringValue: &msg}
+	l.exporter.batcher.Add(l.record)
+}
+
+func (l *OTLPWrapper) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = l.exporter.Flush(ctx)
+}
+
+func (l *OTLPWrapper) Debugf(format string, args ...interface{}) {
+	l.emit(LevelDebug, format, args...)
+}
+
+func (l *OTLPWrapper) Infof(format string, args ...interface{}) {
+	l.emit(LevelInfo, format, args...)
+}
+
+func (l *OTLPWrapper) Warnf(format string, args ...interface{}) {
+	l.emit(LevelWarn, format, args...)
+}
+
+func (l *OTLPWrapper) Fatalf(format string, args ...interface{}) {
+	l.emit(LevelFatal, format, args...)
+	l.flush()
+	os.Exit(1)
+}
+
+func (l *OTLPWrapper) Errorf(format string, args ...interface{}) {
+	l.emit(LevelError, format, args...)
+}
+
+func (l *OTLPWrapper) Panicf(format string, args ...interface{}) {
+	l.emit(LevelPanic, format, args...)
+	l.flush()
+	panic(getFormatedMsg(format, args...))
+}
+
+var otlpSeverityNumbers = map[Level]int{
+	LevelDebug: 5,
+	LevelInfo:  9,
+	LevelWarn:  13,
+	LevelError: 17,
+	LevelFatal: 21,
+	LevelPanic: 22,
+}
+
+// Subset of the OTLP/JSON encoding of ExportLogsServiceRequest, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpExportLogsServiceRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+	Flags                uint32         `json:"flags,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string        `json:"stringValue,omitempty"`
+	BoolValue   *bool          `json:"boolValue,omitempty"`
+	IntValue    *string        `json:"intValue,omitempty"`
+	DoubleValue *float64       `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArray     `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValues `json:"kvlistValue,omitempty"`
+}
+
+type otlpArray struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValues struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+func newOTLPAnyValue(value interface{}) otlpAnyValue {
+	intValue := func(i string) otlpAnyValue { return otlpAnyValue{IntValue: &i} }
+	switch x := value.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &x}
+	case bool:
+		return otlpAnyValue{BoolValue: &x}
+	case int:
+		return intValue(strconv.FormatInt(int64(x), 10))
+	case int8:
+		return intValue(strconv.FormatInt(int64(x), 10))
+	case int16:
+		return intValue(strconv.FormatInt(int64(x), 10))
+	case int32:
+		return intValue(strconv.FormatInt(int64(x), 10))
+	case int64:
+		return intValue(strconv.FormatInt(x, 10))
+	case uint8:
+		return intValue(strconv.FormatUint(uint64(x), 10))
+	case uint16:
+		return intValue(strconv.FormatUint(uint64(x), 10))
+	case uint32:
+		return intValue(strconv.FormatUint(uint64(x), 10))
+	case uint:
+		return newOTLPAnyValue(uint64(x))
+	case uint64:
+		// intValue is a signed 64 bits integer, the larger values are sent as strings
+		if x > math.MaxInt64 {
+			s := strconv.FormatUint(x, 10)
+			return otlpAnyValue{StringValue: &s}
+		}
+		return intValue(strconv.FormatUint(x, 10))
+	case float32:
+		f := float64(x)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &x}
+	case []string:
+		array := otlpArray{Values: make([]otlpAnyValue, 0, len(x))}
+		for _, s := range x {
+			array.Values = append(array.Values, newOTLPAnyValue(s))
+		}
+		return otlpAnyValue{ArrayValue: &array}
+	case Caller:
+		return newOTLPKeyValues("function", x.Function, "file", x.File, "line", x.Line)
+	case StackTrace:
+		array := otlpArray{Values: make([]otlpAnyValue, 0, len(x))}
+		for _, f := range x {
+			array.Values = append(array.Values, newOTLPKeyValues("function", f.Function, "file", f.File, "line", f.Line))
+		}
+		return otlpAnyValue{ArrayValue: &array}
+	case error:
+		s := x.Error()
+		return otlpAnyValue{StringValue: &s}
+	default:
+		s := fmt.Sprintf("%v", value)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func newOTLPKeyValues(keysAndValues ...interface{}) otlpAnyValue {
+	kvs := otlpKeyValues{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		kvs.Values = append(kvs.Values, otlpKeyValue{Key: keysAndValues[i].(string), Value: newOTLPAnyValue(keysAndValues[i+1])})
+	}
+	return otlpAnyValue{KvlistValue: &kvs}
+}
@@ -0,0 +1,215 @@
+package log_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+type otlpRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			LogRecords []struct {
+				TimeUnixNano   string          `json:"timeUnixNano"`
+				SeverityNumber int             `json:"severityNumber"`
+				SeverityText   string          `json:"severityText"`
+				Body           json.RawMessage `json:"body"`
+				Attributes     []otlpKeyValue  `json:"attributes"`
+				TraceID        string          `json:"traceId"`
+				SpanID         string          `json:"spanId"`
+				Flags          uint32          `json:"flags"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type otlpKeyValue struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []otlpRequest
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+	}))
+	defer srv.Close()
+
+	exporter := log.NewOTLPExporter(log.OTLPOptions{
+		Endpoint:       srv.URL + "/v1/logs",
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		Resource:       map[string]interface{}{"service.name": "rockbears", "service.instance": 1},
+		Level:          log.LevelInfo,
+		FlushInterval:  time.Hour,
+		InitialBackoff: time.Millisecond,
+	})
+	logger := log.NewWithFactory(log.NewOTLPWrapper(exporter))
+	logger.RegisterField(fieldComponent)
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine)
+
+	tp, _ := log.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := log.ContextWithTraceParent(context.Background(), tp)
+	ctx = context.WithValue(ctx, fieldComponent, "rockbears/log")
+	logger.Debug(ctx, "this log should not be exported")
+	logger.Info(ctx, "this is %q", "info")
+	logger.Error(ctx, "this is error")
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info(ctx, "this log is dropped after shutdown")
+	if exporter.Dropped() != 1 {
+		t.Fatalf("want 1 dropped record, got %d", exporter.Dropped())
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if calls != 2 || len(requests) != 1 {
+		t.Fatalf("want one retried request, got %d calls", calls)
+	}
+	resourceLogs := requests[0].ResourceLogs[0]
+	if got := resourceLogs.Resource.Attributes; len(got) != 2 || got[0].Key != "service.instance" || string(got[0].Value) != `{"intValue":"1"}` ||
+		got[1].Key != "service.name" || string(got[1].Value) != `{"stringValue":"rockbears"}` {
+		t.Fatalf("unexpected resource %s", got)
+	}
+	records := resourceLogs.ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("want 2 records, got %d", len(records))
+	}
+	info, failure := records[0], records[1]
+	if info.SeverityNumber != 9 || info.SeverityText != "INFO" || string(info.Body) != `{"stringValue":"this is \"info\""}` || info.TimeUnixNano == "" {
+		t.Fatalf("unexpected record %+v", info)
+	}
+	if info.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || info.SpanID != "00f067aa0ba902b7" || info.Flags != 1 {
+		t.Fatalf("unexpected trace context %+v", info)
+	}
+	attributes := map[string]string{}
+	for _, kv := range info.Attributes {
+		attributes[kv.Key] = string(kv.Value)
+	}
+	if attributes["component"] != `{"stringValue":"rockbears/log"}` || attributes["caller"] != `{"stringValue":"github.com/rockbears/log_test.TestOTLPExporter"}` || len(attributes) != 2 {
+		t.Fatalf("unexpected attributes %v", attributes)
+	}
+	if failure.SeverityNumber != 17 || failure.SeverityText != "ERROR" {
+		t.Fatalf("unexpected record %+v", failure)
+	}
+}
+
+func TestOTLPExporterDrop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var dropped int
+	exporter := log.NewOTLPExporter(log.OTLPOptions{
+		Endpoint:      srv.URL,
+		FlushInterval: time.Hour,
+		OnDrop:        func(err error, count int) { dropped += count },
+	})
+	logger := log.NewWithFactory(log.NewOTLPWrapper(exporter))
+	logger.Info(context.Background(), "first")
+	logger.Info(context.Background(), "second")
+
+	if err := exporter.Flush(context.Background()); err == nil {
+		t.Fatal("want error")
+	}
+	if dropped != 2 || exporter.Dropped() != 2 {
+		t.Fatalf("want 2 dropped records, got %d", dropped)
+	}
+}
+
+func TestOTLPExporterFlushTimeout(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	// The background flush waits for its next retry for an hour
+	exporter := log.NewOTLPExporter(log.OTLPOptions{
+		Endpoint:       srv.URL,
+		BatchSize:      1,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+	})
+	logger := log.NewWithFactory(log.NewOTLPWrapper(exporter))
+	logger.Info(context.Background(), "first")
+	<-requested
+	logger.Info(context.Background(), "second")
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := exporter.Flush(ctx); err == nil {
+		t.Fatal("want error")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err == nil {
+		t.Fatal("want error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("flush and shutdown should give up with their context, took %s", elapsed)
+	}
+}
+
+func TestOTLPExporterUnsignedValues(t *testing.T) {
+	var request otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	exporter := log.NewOTLPExporter(log.OTLPOptions{
+		Endpoint:      srv.URL,
+		Resource:      map[string]interface{}{"uint": uint(7), "uint64": uint64(math.MaxInt64), "uint64.max": uint64(math.MaxUint64)},
+		FlushInterval: time.Hour,
+	})
+	logger := log.NewWithFactory(log.NewOTLPWrapper(exporter))
+	logger.Info(context.Background(), "this is info")
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	attributes := map[string]string{}
+	for _, kv := range request.ResourceLogs[0].Resource.Attributes {
+		attributes[kv.Key] = string(kv.Value)
+	}
+	if attributes["uint"] != `{"intValue":"7"}` || attributes["uint64"] != `{"intValue":"9223372036854775807"}` ||
+		attributes["uint64.max"] != `{"stringValue":"18446744073709551615"}` {
+		t.Fatalf("unexpected attributes %v", attributes)
+	}
+}
@@ -0,0 +1,180 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldPropagation describes how fields are carried by HTTP headers from a service to another.
+type FieldPropagation struct {
+	// Fields lists the fields sent by the client and accepted by the server, any other field is ignored
+	Fields []Field
+	// HeaderPrefix defaults to X-Log-Field-, each field is sent in its own header
+	HeaderPrefix string
+	// Baggage sends the fields as the members of a single W3C baggage header instead
+	Baggage bool
+	// BaggageHeader defaults to baggage
+	BaggageHeader string
+	// MaxValueSize defaults to 256 bytes, longer values are neither sent nor accepted
+	MaxValueSize int
+	// MaxHeaderSize defaults to 8192 bytes, it limits the total size of the headers carrying fields
+	MaxHeaderSize int
+}
+
+func (p FieldPropagation) withDefaults() FieldPropagation {
+	if p.HeaderPrefix == "" {
+		p.HeaderPrefix = "X-Log-Field-"
+	}
+	if p.BaggageHeader == "" {
+		p.BaggageHeader = "baggage"
+	}
+	if p.MaxValueSize <= 0 {
+		p.MaxValueSize = 256
+	}
+	if p.MaxHeaderSize <= 0 {
+		p.MaxHeaderSize = 8192
+	}
+	return p
+}
+
+// Inject adds the values of the propagated fields found in ctx to the headers.
+func (p FieldPropagation) Inject(ctx context.Context, header http.Header) {
+	p = p.withDefaults()
+	var members []string
+	var size int
+	for _, f := range p.Fields {
+		v := ctx.Value(f)
+		if v == nil || !isToken(string(f)) {
+			continue
+		}
+		value := fmt.Sprintf("%v", v)
+		if len(value) > p.MaxValueSize {
+			continue
+		}
+		encoded := url.PathEscape(value)
+		if p.Baggage {
+			member := string(f) + "=" + encoded
+			if size+len(member)+1 > p.MaxHeaderSize {
+				continue
+			}
+			size += len(member) + 1
+			members = append(members, member)
+		} else {
+			name := p.HeaderPrefix + string(f)
+			if size+len(name)+len(encoded) > p.MaxHeaderSize {
+				continue
+			}
+			size += len(name) + len(encoded)
+			header.Set(name, encoded)
+		}
+	}
+	if len(members) > 0 {
+		if existing := header.Get(p.BaggageHeader); existing != "" {
+			members = append([]string{existing}, members...)
+		}
+		header.Set(p.BaggageHeader, strings.Join(members, ","))
+	}
+}
+
+// Extract adds the values of the propagated fields found in the headers to ctx, as strings.
+// Fields which are not listed, values which are too large or not printable and oversized headers are ignored.
+func (p FieldPropagation) Extract(ctx context.Context, header http.Header) context.Context {
+	p = p.withDefaults()
+	allowed := make(map[string]Field, len(p.Fields))
+	for _, f := range p.Fields {
+		allowed[string(f)] = f
+	}
+
+	accept := func(ctx context.Context, key, encoded string) context.Context {
+		f, ok := allowed[key]
+		if !ok {
+			return ctx
+		}
+		value, err := url.PathUnescape(encoded)
+		if err != nil || len(value) > p.MaxValueSize || !isPrintable(value) {
+			return ctx
+		}
+		return context.WithValue(ctx, f, value)
+	}
+
+	if p.Baggage {
+		baggage := strings.Join(header.Values(p.BaggageHeader), ",")
+		if len(baggage) > p.MaxHeaderSize {
+			return ctx
+		}
+		for _, member := range strings.Split(baggage, ",") {
+			// Member properties, after the first semicolon, are ignored
+			member, _, _ = strings.Cut(member, ";")
+			key, value, ok := strings.Cut(member, "=")
+			if ok {
+				ctx = accept(ctx, strings.TrimSpace(key), strings.TrimSpace(value))
+			}
+		}
+		return ctx
+	}
+
+	var size int
+	for _, f := range p.Fields {
+		name := p.HeaderPrefix + string(f)
+		value := header.Get(name)
+		if value == "" {
+			continue
+		}
+		size += len(name) + len(value)
+		if size > p.MaxHeaderSize {
+			return ctx
+		}
+		ctx = accept(ctx, string(f), value)
+	}
+	return ctx
+}
+
+// NewFieldTransport returns a http.RoundTripper adding the propagated fields of the request context to its headers.
+// http.DefaultTransport is used if base is nil.
+func NewFieldTransport(base http.RoundTripper, propagation FieldPropagation) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &fieldTransport{base: base, propagation: propagation}
+}
+
+type fieldTransport struct {
+	base        http.RoundTripper
+	propagation FieldPropagation
+}
+
+func (t *fieldTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	t.propagation.Inject(req.Context(), req.Header)
+	return t.base.RoundTrip(req)
+}
+
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c > unicode.MaxASCII || c <= ' ' || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrintable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, c := range s {
+		if !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
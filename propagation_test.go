@@ -0,0 +1,93 @@
+package log_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rockbears/log"
+)
+
+const fieldTenant = log.Field("tenant")
+
+func TestFieldTransport(t *testing.T) {
+	for _, baggage := range []bool{false, true} {
+		propagation := log.FieldPropagation{Fields: []log.Field{fieldTenant, fieldComponent}, Baggage: baggage}
+
+		var mutex sync.Mutex
+		var lines []string
+		var fields []map[string]interface{}
+		logger := log.NewWithFactory(func() log.Wrapper {
+			return &recordingWrapper{mutex: &mutex, lines: &lines, fields: &fields}
+		})
+		var received http.Header
+		srv := httptest.NewServer(log.HTTPMiddleware(logger, log.HTTPMiddlewareOptions{Propagation: &propagation, DisableAccessLog: true})(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Clone()
+				logger.Info(r.Context(), "handling request")
+			})))
+
+		ctx := context.WithValue(context.Background(), fieldTenant, "acme, inc=1")
+		ctx = context.WithValue(ctx, fieldComponent, 42)
+		ctx = context.WithValue(ctx, fieldAsset, "not propagated")
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		client := &http.Client{Transport: log.NewFieldTransport(nil, propagation)}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		srv.Close()
+
+		if len(req.Header) != 0 {
+			t.Fatalf("the request should not be modified, got %v", req.Header)
+		}
+		if baggage {
+			if got := received.Get("baggage"); got != "tenant=acme%2C%20inc=1,component=42" {
+				t.Fatalf("unexpected baggage %q", got)
+			}
+		} else if got := received.Get("X-Log-Field-Tenant"); got != "acme%2C%20inc=1" {
+			t.Fatalf("unexpected header %q", got)
+		}
+		if len(fields) != 1 || fields[0]["tenant"] != "acme, inc=1" || fields[0]["component"] != "42" {
+			t.Fatalf("unexpected fields %v", fields)
+		}
+		if _, has := fields[0]["asset"]; has {
+			t.Fatalf("asset should not be propagated, got %v", fields)
+		}
+	}
+}
+
+func TestFieldPropagationExtractLimits(t *testing.T) {
+	propagation := log.FieldPropagation{Fields: []log.Field{fieldTenant}, MaxValueSize: 8}
+
+	header := http.Header{}
+	header.Set("X-Log-Field-Tenant", "acme")
+	header.Set("X-Log-Field-Asset", "injected")
+	ctx := propagation.Extract(context.Background(), header)
+	if ctx.Value(fieldTenant) != "acme" || ctx.Value(fieldAsset) != nil {
+		t.Fatalf("unexpected values %v %v", ctx.Value(fieldTenant), ctx.Value(fieldAsset))
+	}
+
+	for _, invalid := range []string{"too-long-value", "%0Anewline", "%zz"} {
+		header.Set("X-Log-Field-Tenant", invalid)
+		if v := propagation.Extract(context.Background(), header).Value(fieldTenant); v != nil {
+			t.Errorf("want %q to be ignored, got %v", invalid, v)
+		}
+	}
+
+	propagation.Baggage = true
+	propagation.MaxHeaderSize = 40
+	header = http.Header{}
+	header.Set("baggage", "other=1;property,tenant=acme;prop=1")
+	if v := propagation.Extract(context.Background(), header).Value(fieldTenant); v != "acme" {
+		t.Fatalf("want acme, got %v", v)
+	}
+	header.Set("baggage", "tenant=acme,"+strings.Repeat("x", 40))
+	if v := propagation.Extract(context.Background(), header).Value(fieldTenant); v != nil {
+		t.Fatalf("oversized header should be ignored, got %v", v)
+	}
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+type RecoverOptions struct {
+	// PanicLevel logs the recovered panics at LevelPanic instead of LevelError. Most backends panic when logging at LevelPanic.
+	PanicLevel bool
+	// Repanic panics again with the recovered value once it has been logged.
+	Repanic bool
+}
+
+func (l *Logger) GetRecoverOptions() RecoverOptions {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.recoverOptions
+}
+
+func (l *Logger) SetRecoverOptions(opts RecoverOptions) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.recoverOptions = opts
+}
+
+// Recover must be deferred. It logs the recovered panic with its stack trace and the fields of the context.
+func (l *Logger) Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		l.handlePanic(ctx, r)
+	}
+}
+
+// Go runs fn in a new goroutine protected by Recover.
+func (l *Logger) Go(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer l.Recover(ctx)
+		fn(ctx)
+	}()
+}
+
+func (l *Logger) handlePanic(ctx context.Context, r interface{}) {
+	l.mutex.RLock()
+	opts := l.recoverOptions
+	stackTraceOptions := l.stackTraceOptions
+	callerOptions := l.effectiveCallerOptions()
+	helperPrefixes := l.copyHelperPrefixes()
+	l.mutex.RUnlock()
+
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+
+	// The frames of the runtime are skipped, the stack starts at the function that panicked
+	ctx = ContextWithError(ctx, err)
+	ctx = contextWithCapturedStackTrace(ctx, err, l.callers(1, 0, helperPrefixes), stackTraceOptions, callerOptions)
+
+	level := LevelError
+	if opts.PanicLevel {
+		level = LevelPanic
+	}
+	l.call(ctx, 0, level, "panic recovered: %v", r)
+
+	if opts.Repanic {
+		panic(r)
+	}
+}
+
+func GetRecoverOptions() RecoverOptions {
+	return global.GetRecoverOptions()
+}
+
+func SetRecoverOptions(opts RecoverOptions) {
+	global.SetRecoverOptions(opts)
+}
+
+// Recover must be deferred. It logs the recovered panic with its stack trace and the fields of the context.
+func Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		global.handlePanic(ctx, r)
+	}
+}
+
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	global.Go(ctx, fn)
+}
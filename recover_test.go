@@ -0,0 +1,81 @@
+package log_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+func panicking() {
+	var m map[string]int
+	m["key"] = 1
+}
+
+func TestRecover(t *testing.T) {
+	w := &callerWrapper{fields: map[string]interface{}{}}
+	logger := log.NewWithFactory(func() log.Wrapper { return w })
+	logger.RegisterField(fieldComponent)
+	ctx := context.WithValue(context.Background(), fieldComponent, "rockbears/log")
+
+	func() {
+		defer logger.Recover(ctx)
+		panicking()
+	}()
+
+	if got, want := w.fields[string(log.FieldCaller)], "github.com/rockbears/log_test.panicking"; got != want {
+		t.Fatalf("want caller %q, got %q", want, got)
+	}
+	if got, want := w.fields[string(fieldComponent)], "rockbears/log"; got != want {
+		t.Fatalf("want component %q, got %q", want, got)
+	}
+	if got, want := w.fields[string(log.FieldErrorType)], "runtime.plainError"; got != want {
+		t.Fatalf("want error type %q, got %q", want, got)
+	}
+	stackTrace, _ := w.fields[string(log.FieldStackTrace)].(string)
+	lines := strings.Split(stackTrace, "\n")
+	if len(lines) < 2 || lines[0] != "assignment to entry in nil map" || !strings.HasSuffix(lines[1], "log_test.panicking") {
+		t.Fatalf("unexpected stack trace %q", stackTrace)
+	}
+
+	logger.SetRecoverOptions(log.RecoverOptions{Repanic: true})
+	defer func() {
+		if r := recover(); r != "repanic" {
+			t.Fatalf("want repanic, got %v", r)
+		}
+	}()
+	defer logger.Recover(ctx)
+	panic("repanic")
+}
+
+func TestGo(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	defer func(factory log.WrapperFactoryFunc) { log.Factory = factory }(log.Factory)
+	log.Factory = func() log.Wrapper {
+		return &recordingWrapper{mutex: &mutex, lines: &lines}
+	}
+
+	log.Go(context.Background(), func(ctx context.Context) {
+		panic("in goroutine")
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mutex.Lock()
+		n := len(lines)
+		mutex.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(lines) != 1 || lines[0] != "[ERROR] panic recovered: in goroutine" {
+		t.Fatalf("unexpected logs %v", lines)
+	}
+}
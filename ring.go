@@ -0,0 +1,336 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* In-memory ring buffer wrapper */
+
+type RingOptions struct {
+	// Size defaults to 1000, the oldest entries are overwritten once it is reached
+	Size  int
+	Level Level
+}
+
+type RingEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   Level                  `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Ring keeps the last log entries in memory. It is safe for concurrent use and serves the entries over HTTP.
+type Ring struct {
+	opts    RingOptions
+	mutex   sync.RWMutex
+	entries []RingEntry
+	next    int
+	full    bool
+}
+
+func NewRing(opts RingOptions) *Ring {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	return &Ring{opts: opts, entries: make([]RingEntry, opts.Size)}
+}
+
+func (r *Ring) add(e RingEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns a copy of the entries kept by the ring, oldest first.
+func (r *Ring) Entries() []RingEntry {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if !r.full {
+		return append([]RingEntry(nil), r.entries[:r.next]...)
+	}
+	entries := make([]RingEntry, 0, len(r.entries))
+	entries = append(entries, r.entries[r.next:]...)
+	return append(entries, r.entries[:r.next]...)
+}
+
+// ServeHTTP serves the entries as JSON, or as an HTML table with format=html or when the client accepts text/html.
+// The level parameter sets the minimum level, limit keeps the most recent entries only,
+// and any other parameter filters the entries on the value of the field of the same name.
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	var minLevel Level
+	if s := query.Get("level"); s != "" {
+		level, err := ParseLevel(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		minLevel = level
+	}
+	var limit int
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", s), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	format := query.Get("format")
+	if format == "" && strings.Contains(req.Header.Get("Accept"), "text/html") {
+		format = "html"
+	}
+	filters := map[string]string{}
+	for k := range query {
+		if k != "level" && k != "limit" && k != "format" {
+			filters[k] = query.Get(k)
+		}
+	}
+
+	entries := filterRingEntries(r.Entries(), minLevel, filters)
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := ringTemplate.Execute(w, newRingPage(entries, minLevel, filters)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func filterRingEntries(entries []RingEntry, minLevel Level, filters map[string]string) []RingEntry {
+	filtered := make([]RingEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Level < minLevel {
+			continue
+		}
+		match := true
+		for k, v := range filters {
+			value, ok := e.Fields[k]
+			if !ok || fmt.Sprintf("%v", value) != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// NewRingWrapper returns a factory writing to the ring. Fatal and Panic entries are recorded without exiting
+// or panicking, so that the ring can be composed with the main backend with NewMultiWrapper.
+func NewRingWrapper(ring *Ring) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &RingWrapper{ring: ring}
+	}
+}
+
+type RingWrapper struct {
+	ring   *Ring
+	fields map[string]interface{}
+	time   time.Time
+}
+
+func (l *RingWrapper) GetLevel() Level {
+	return l.ring.opts.Level
+}
+
+func (l *RingWrapper) WithField(key string, value interface{}) {
+	if l.fields == nil {
+		l.fields = map[string]interface{}{}
+	}
+	switch x := value.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, Caller, StackTrace:
+		l.fields[key] = x
+	default:
+		// Keeps the entries printable as JSON whatever the values are
+		l.fields[key] = fmt.Sprintf("%v", value)
+	}
+}
+
+func (l *RingWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *RingWrapper) add(level Level, format string, args ...interface{}) {
+	t := l.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	l.ring.add(RingEntry{Time: t, Level: level, Message: getFormatedMsg(format, args...), Fields: l.fields})
+}
+
+func (l *RingWrapper) Debugf(format string, args ...interface{}) {
+	l.add(LevelDebug, format, args...)
+}
+
+func (l *RingWrapper) Infof(format string, args ...interface{}) {
+	l.add(LevelInfo, format, args...)
+}
+
+func (l *RingWrapper) Warnf(format string, args ...interface{}) {
+	l.add(LevelWarn, format, args...)
+}
+
+func (l *RingWrapper) Fatalf(format string, args ...interface{}) {
+	l.add(LevelFatal, format, args...)
+}
+
+func (l *RingWrapper) Errorf(format string, args ...interface{}) {
+	l.add(LevelError, format, args...)
+}
+
+func (l *RingWrapper) Panicf(format string, args ...interface{}) {
+	l.add(LevelPanic, format, args...)
+}
+
+type ringPage struct {
+	Levels  []Level
+	Level   Level
+	Filters string
+	Fields  []string
+	Entries []RingEntry
+}
+
+func newRingPage(entries []RingEntry, minLevel Level, filters map[string]string) ringPage {
+	page := ringPage{Levels: []Level{LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal, LevelPanic}, Level: minLevel, Entries: entries}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		for k := range e.Fields {
+			if !seen[k] {
+				seen[k] = true
+				page.Fields = append(page.Fields, k)
+			}
+		}
+	}
+	sort.Strings(page.Fields)
+	var parts []string
+	for k, v := range filters {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	page.Filters = strings.Join(parts, "&")
+	return page
+}
+
+var ringTemplate = template.Must(template.New("ring").Funcs(template.FuncMap{
+	"field": func(e RingEntry, k string) string {
+		if v, ok := e.Fields[k]; ok {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Logs</title>
+<style>body{font-family:sans-serif}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:2px 6px;text-align:left;vertical-align:top}</style>
+</head>
+<body>
+<form method="get">
+<input type="hidden" name="format" value="html">
+<select name="level">{{range .Levels}}<option value="{{.}}"{{if eq . $.Level}} selected{{end}}>{{.}}</option>{{end}}</select>
+<button type="submit">Filter</button>
+{{if .Filters}}<span>{{.Filters}}</span>{{end}}
+</form>
+<table>
+<tr><th>time</th><th>level</th><th>message</th>{{range .Fields}}<th>{{.}}</th>{{end}}</tr>
+{{range $e := .Entries}}<tr><td>{{$e.Time.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{$e.Level}}</td><td>{{$e.Message}}</td>{{range $.Fields}}<td>{{field $e .}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+/* Multi wrapper */
+
+// NewMultiWrapper returns a factory writing each entry to all the wrappers whose level accepts it.
+// Wrappers are called in order, the ones exiting or panicking on Fatal and Panic entries should be last.
+func NewMultiWrapper(factories ...WrapperFactoryFunc) WrapperFactoryFunc {
+	return func() Wrapper {
+		w := &MultiWrapper{wrappers: make([]Wrapper, 0, len(factories))}
+		for _, f := range factories {
+			w.wrappers = append(w.wrappers, f())
+		}
+		return w
+	}
+}
+
+type MultiWrapper struct {
+	wrappers []Wrapper
+}
+
+func (l *MultiWrapper) GetLevel() Level {
+	level := LevelPanic
+	for _, w := range l.wrappers {
+		level = min(level, w.GetLevel())
+	}
+	return level
+}
+
+func (l *MultiWrapper) WithField(key string, value interface{}) {
+	for _, w := range l.wrappers {
+		w.WithField(key, value)
+	}
+}
+
+func (l *MultiWrapper) WithTime(t time.Time) {
+	for _, w := range l.wrappers {
+		if tw, ok := w.(TimeWrapper); ok {
+			tw.WithTime(t)
+		}
+	}
+}
+
+func (l *MultiWrapper) each(level Level, fn func(w Wrapper)) {
+	for _, w := range l.wrappers {
+		if level >= w.GetLevel() {
+			fn(w)
+		}
+	}
+}
+
+func (l *MultiWrapper) Debugf(format string, args ...interface{}) {
+	l.each(LevelDebug, func(w Wrapper) { w.Debugf(format, args...) })
+}
+
+func (l *MultiWrapper) Infof(format string, args ...interface{}) {
+	l.each(LevelInfo, func(w Wrapper) { w.Infof(format, args...) })
+}
+
+func (l *MultiWrapper) Warnf(format string, args ...interface{}) {
+	l.each(LevelWarn, func(w Wrapper) { w.Warnf(format, args...) })
+}
+
+func (l *MultiWrapper) Fatalf(format string, args ...interface{}) {
+	l.each(LevelFatal, func(w Wrapper) { w.Fatalf(format, args...) })
+}
+
+func (l *MultiWrapper) Errorf(format string, args ...interface{}) {
+	l.each(LevelError, func(w Wrapper) { w.Errorf(format, args...) })
+}
+
+func (l *MultiWrapper) Panicf(format string, args ...interface{}) {
+	l.each(LevelPanic, func(w Wrapper) { w.Panicf(format, args...) })
+}
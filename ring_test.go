@@ -0,0 +1,92 @@
+package log_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rockbears/log"
+)
+
+func ExampleNewRingWrapper() {
+	ring := log.NewRing(log.RingOptions{Size: 2})
+	logger := log.NewWithFactory(log.NewMultiWrapper(
+		log.NewRingWrapper(ring),
+		log.NewStdWrapper(log.StdWrapperOptions{Level: log.LevelWarn, DisableTimestamp: true}),
+	))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+
+	logger.Info(context.Background(), "first")
+	logger.Info(context.Background(), "second")
+	logger.Warn(context.Background(), "third")
+	for _, e := range ring.Entries() {
+		fmt.Println(e.Level, e.Message)
+	}
+	// Output:
+	// [WARN]  third
+	// info second
+	// warn third
+}
+
+func TestRingConcurrent(t *testing.T) {
+	ring := log.NewRing(log.RingOptions{Size: 100})
+	logger := log.NewWithFactory(log.NewRingWrapper(ring))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logger.Info(context.Background(), "entry %d", j)
+				_ = ring.Entries()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(ring.Entries()); n != 100 {
+		t.Fatalf("want 100 entries, got %d", n)
+	}
+}
+
+func TestRingHandler(t *testing.T) {
+	ring := log.NewRing(log.RingOptions{})
+	logger := log.NewWithFactory(log.NewRingWrapper(ring))
+	logger.RegisterField(fieldComponent)
+
+	ctx := context.WithValue(context.Background(), fieldComponent, "api")
+	logger.Debug(ctx, "debug api")
+	logger.Error(ctx, "error api")
+	logger.Error(context.Background(), "error without component")
+
+	rec := httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?level=warn&component=api", nil))
+	var entries []log.RingEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "error api" || entries[0].Level != log.LevelError || entries[0].Fields["component"] != "api" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	rec = httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=html&limit=1", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "error without component") || strings.Contains(body, "error api") {
+		t.Fatalf("unexpected page %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?level=verbose", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("want status 400, got %d", rec.Code)
+	}
+}
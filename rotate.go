@@ -0,0 +1,323 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/* Rotating file */
+
+const rotatingFileTimeFormat = "2006-01-02T15-04-05.000"
+
+type RotatingFileOptions struct {
+	Filename string
+	// MaxSize is the size in bytes above which the file is rotated, 0 disables rotation by size
+	MaxSize int64
+	// Interval rotates the file at each multiple of the interval since the zero time, such as every hour or every day at midnight UTC.
+	// 0 disables rotation by time.
+	Interval time.Duration
+	// Compress gzips the rotated files
+	Compress bool
+	// MaxAge and MaxBackups delete the rotated files older than MaxAge or beyond the MaxBackups most recent ones, 0 keeps them
+	MaxAge     time.Duration
+	MaxBackups int
+	// ReopenOnSIGHUP reopens the file when the process receives SIGHUP, after an external tool moved it
+	ReopenOnSIGHUP bool
+	// Clock defaults to time.Now
+	Clock func() time.Time
+}
+
+// RotatingFile is an io.Writer to a file, rotated by size and by time. It is safe for concurrent use
+// and can be the output of StdWrapper, logrus or zap.
+type RotatingFile struct {
+	opts         RotatingFileOptions
+	mutex        sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+	closed       bool
+	// cleaning serializes the compression and deletion of the rotated files, done in the background
+	cleaning sync.Mutex
+	wg       sync.WaitGroup
+	signals  chan os.Signal
+}
+
+func NewRotatingFile(opts RotatingFileOptions) (*RotatingFile, error) {
+	if opts.Filename == "" {
+		return nil, errors.New("rotating file name is empty")
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	f := &RotatingFile{opts: opts}
+	if err := os.MkdirAll(filepath.Dir(opts.Filename), 0o755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if opts.ReopenOnSIGHUP {
+		f.signals = make(chan os.Signal, 1)
+		signal.Notify(f.signals, syscall.SIGHUP)
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			for range f.signals {
+				_ = f.Reopen()
+			}
+		}()
+	}
+	return f, nil
+}
+
+// open replaces the current file, which is kept if the new one cannot be opened. It must be called with the mutex held.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.opts.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if f.file != nil {
+		_ = f.file.Close()
+	}
+	f.file, f.size = file, info.Size()
+	if f.opts.Interval > 0 {
+		f.nextRotation = f.opts.Clock().Truncate(f.opts.Interval).Add(f.opts.Interval)
+	}
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	bySize := f.opts.MaxSize > 0 && f.size+int64(len(p)) > f.opts.MaxSize
+	byTime := f.opts.Interval > 0 && !f.opts.Clock().Before(f.nextRotation)
+	// An empty file is not rotated. If the rotation fails, the current file is kept and the next write retries it.
+	if (bySize || byTime) && f.size > 0 {
+		_ = f.rotate()
+	} else if byTime {
+		f.nextRotation = f.opts.Clock().Truncate(f.opts.Interval).Add(f.opts.Interval)
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside and starts a new one.
+func (f *RotatingFile) Rotate() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate must be called with the mutex held. The current file is only closed once the new one is opened.
+func (f *RotatingFile) rotate() error {
+	now := f.opts.Clock()
+	if err := os.Rename(f.opts.Filename, f.backupName(now)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.clean(now)
+	}()
+	return nil
+}
+
+// Reopen closes and reopens the file, to be used once an external tool moved it.
+func (f *RotatingFile) Reopen() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.open()
+}
+
+// Close closes the file and waits for the rotated files to be compressed and deleted.
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	err := f.file.Close()
+	f.mutex.Unlock()
+
+	if f.signals != nil {
+		signal.Stop(f.signals)
+		close(f.signals)
+	}
+	f.wg.Wait()
+	return err
+}
+
+// backupName returns the name of a rotated file, such as app-2006-01-02T15-04-05.000.log for app.log.
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := f.nameParts()
+	name := filepath.Join(dir, prefix+t.UTC().Format(rotatingFileTimeFormat)+ext)
+	for i := 1; ; i++ {
+		_, err := os.Stat(name)
+		_, errGz := os.Stat(name + ".gz")
+		if errors.Is(err, os.ErrNotExist) && errors.Is(errGz, os.ErrNotExist) {
+			return name
+		}
+		name = filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", prefix, t.UTC().Format(rotatingFileTimeFormat), i, ext))
+	}
+}
+
+func (f *RotatingFile) nameParts() (dir, prefix, ext string) {
+	base := filepath.Base(f.opts.Filename)
+	ext = filepath.Ext(base)
+	return filepath.Dir(f.opts.Filename), strings.TrimSuffix(base, ext) + "-", ext
+}
+
+type rotatedFile struct {
+	path string
+	time time.Time
+	// seq tells apart the files rotated at the same time
+	seq int
+}
+
+// Backups returns the paths of the rotated files, most recent first.
+func (f *RotatingFile) Backups() ([]string, error) {
+	backups, err := f.backups()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(backups))
+	for i, b := range backups {
+		paths[i] = b.path
+	}
+	return paths, nil
+}
+
+func (f *RotatingFile) backups() ([]rotatedFile, error) {
+	dir, prefix, ext := f.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []rotatedFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ext)
+		if len(stamp) < len(rotatingFileTimeFormat) {
+			continue
+		}
+		t, err := time.Parse(rotatingFileTimeFormat, stamp[:len(rotatingFileTimeFormat)])
+		if err != nil {
+			continue
+		}
+		var seq int
+		if rest := stamp[len(rotatingFileTimeFormat):]; rest != "" {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(rest, ".")); err != nil || !strings.HasPrefix(rest, ".") {
+				continue
+			}
+		}
+		backups = append(backups, rotatedFile{path: filepath.Join(dir, name), time: t, seq: seq})
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].time.Equal(backups[j].time) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// clean compresses the rotated files and deletes the old ones. Errors are ignored, the next rotation retries.
+func (f *RotatingFile) clean(now time.Time) {
+	f.cleaning.Lock()
+	defer f.cleaning.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		return
+	}
+	var kept []rotatedFile
+	for i, b := range backups {
+		if (f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups) || (f.opts.MaxAge > 0 && now.Sub(b.time) > f.opts.MaxAge) {
+			_ = os.Remove(b.path)
+			continue
+		}
+		kept = append(kept, b)
+	}
+	if !f.opts.Compress {
+		return
+	}
+	for _, b := range kept {
+		if !strings.HasSuffix(b.path, ".gz") {
+			_ = gzipFile(b.path)
+		}
+	}
+}
+
+func gzipFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,213 @@
+package log_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f, err := log.NewRotatingFile(log.RotatingFileOptions{
+		Filename:   path,
+		MaxSize:    20,
+		Compress:   true,
+		MaxBackups: 2,
+		Clock: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(f, "line %d...........\n", i)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "line 3..........." {
+		t.Fatalf("unexpected current file %v", lines)
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("want 2 backups, got %v", backups)
+	}
+	for i, want := range []string{"line 2...........", "line 1..........."} {
+		if !strings.HasSuffix(backups[i], ".log.gz") {
+			t.Fatalf("backup %s should be compressed", backups[i])
+		}
+		if lines := readLines(t, backups[i]); len(lines) != 1 || lines[0] != want {
+			t.Fatalf("unexpected backup %s: %v", backups[i], lines)
+		}
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	f, err := log.NewRotatingFile(log.RotatingFileOptions{
+		Filename: path,
+		Interval: time.Hour,
+		MaxAge:   90 * time.Minute,
+		Clock:    func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "at 12:30")
+	now = now.Add(20 * time.Minute)
+	fmt.Fprintln(f, "at 12:50")
+	now = now.Add(20 * time.Minute)
+	fmt.Fprintln(f, "at 13:10")
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(10 * time.Minute)
+	fmt.Fprintln(f, "at 13:20")
+	now = now.Add(170 * time.Minute)
+	fmt.Fprintln(f, "at 16:10")
+	f.Close()
+
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "at 16:10" {
+		t.Fatalf("unexpected current file %v", lines)
+	}
+	// The files rotated at 13:10 are older than MaxAge
+	backups, _ := f.Backups()
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], "app-2024-05-01T16-10-00.000.log") {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := log.NewRotatingFile(log.RotatingFileOptions{Filename: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "before")
+	if err := os.Rename(path, filepath.Join(dir, "moved.log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, "after")
+
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "after" {
+		t.Fatalf("unexpected current file %v", lines)
+	}
+	if lines := readLines(t, filepath.Join(dir, "moved.log")); len(lines) != 1 || lines[0] != "before" {
+		t.Fatalf("unexpected moved file %v", lines)
+	}
+}
+
+func TestRotatingFileFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	f, err := log.NewRotatingFile(log.RotatingFileOptions{Filename: path, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "first line")
+	// The rotation fails while the directory is missing, the writes go on to the current file
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fmt.Fprintln(f, "second line"); err != nil {
+		t.Fatalf("the write should not fail with the rotation: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fmt.Fprintln(f, "third line"); err != nil {
+		t.Fatal(err)
+	}
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "third line" {
+		t.Fatalf("unexpected current file %v", lines)
+	}
+}
+
+func TestRotatingFileStdWrapper(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := log.NewRotatingFile(log.RotatingFileOptions{Filename: path, MaxSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{Output: f}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logger.Info(context.Background(), "writer %d entry %d", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	f.Close()
+
+	backups, _ := f.Backups()
+	var count int
+	for _, p := range append(backups, path) {
+		for _, line := range readLines(t, p) {
+			if !strings.Contains(line, " [INFO]  writer ") {
+				t.Fatalf("unexpected line %q in %s", line, p)
+			}
+			count++
+		}
+	}
+	if count != 400 {
+		t.Fatalf("want 400 lines, got %d", count)
+	}
+}
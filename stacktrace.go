@@ -0,0 +1,216 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+type StackTraceOptions struct {
+	// CaptureIfMissing captures the stack of the log call site when no error of the chain carries a stack trace.
+	CaptureIfMissing bool
+	// Structured emits FieldStackTrace as a StackTrace instead of a string. The frames are formatted with the CallerOptions.
+	Structured bool
+	// MaxDepth limits the number of frames of a structured stack trace, 0 means no limit.
+	MaxDepth int
+	// ExcludeRuntime, ExcludeTesting and ExcludeVendor drop the frames of the runtime and testing packages,
+	// and of vendored files, from structured stack traces.
+	ExcludeRuntime bool
+	ExcludeTesting bool
+	ExcludeVendor  bool
+}
+
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+func (f StackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+	return nil
+}
+
+type StackTrace []StackFrame
+
+// String renders one frame per indented function and file:line pair, for text backends.
+func (s StackTrace) String() string {
+	var sb strings.Builder
+	for _, f := range s {
+		fmt.Fprintf(&sb, "\n\t%s\n\t\t%s:%d", f.Function, f.File, f.Line)
+	}
+	return sb.String()
+}
+
+func (s StackTrace) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, f := range s {
+		if err := enc.AppendObject(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newStackTrace(pcs []uintptr, opts StackTraceOptions, callerOptions CallerOptions) StackTrace {
+	var stackTrace StackTrace
+	if len(pcs) == 0 {
+		return stackTrace
+	}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		pkg := functionPackage(frame.Function)
+		switch {
+		case opts.ExcludeRuntime && (pkg == "runtime" || strings.HasPrefix(pkg, "runtime/")):
+		case opts.ExcludeTesting && pkg == "testing":
+		case opts.ExcludeVendor && strings.Contains(filepath.ToSlash(frame.File), "/vendor/"):
+		default:
+			stackTrace = append(stackTrace, StackFrame(newCaller(frame, callerOptions)))
+		}
+		if !more || (opts.MaxDepth > 0 && len(stackTrace) >= opts.MaxDepth) {
+			return stackTrace
+		}
+	}
+}
+
+func (l *Logger) GetStackTraceOptions() StackTraceOptions {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.stackTraceOptions
+}
+
+func (l *Logger) SetStackTraceOptions(opts StackTraceOptions) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.stackTraceOptions = opts
+}
+
+// ContextWithStackTrace walks the chain of err, including multi-errors, and adds the stack traces found to the context,
+// with the StackTraceOptions of the logger.
+func (l *Logger) ContextWithStackTrace(ctx context.Context, err error) context.Context {
+	return l.contextWithStackTrace(ctx, err)
+}
+
+func (l *Logger) contextWithStackTrace(ctx context.Context, err error) context.Context {
+	l.mutex.RLock()
+	opts := l.stackTraceOptions
+	callerOptions := l.effectiveCallerOptions()
+	helperPrefixes := l.copyHelperPrefixes()
+	l.mutex.RUnlock()
+
+	tracers := stackTracers(err)
+	if len(tracers) > 0 {
+		if !opts.Structured {
+			return context.WithValue(ctx, FieldStackTrace, formatStackTraces(tracers))
+		}
+		var stackTrace StackTrace
+		for _, st := range tracers {
+			pcs := make([]uintptr, len(st.StackTrace()))
+			for i, f := range st.StackTrace() {
+				pcs[i] = uintptr(f)
+			}
+			stackTrace = append(stackTrace, newStackTrace(pcs, opts, callerOptions)...)
+		}
+		return context.WithValue(ctx, FieldStackTrace, stackTrace)
+	}
+
+	if !opts.CaptureIfMissing {
+		return ctx
+	}
+	return contextWithCapturedStackTrace(ctx, err, l.callers(1, 0, helperPrefixes), opts, callerOptions)
+}
+
+func contextWithCapturedStackTrace(ctx context.Context, err error, pcs []uintptr, opts StackTraceOptions, callerOptions CallerOptions) context.Context {
+	if len(pcs) == 0 {
+		return ctx
+	}
+	if opts.Structured {
+		return context.WithValue(ctx, FieldStackTrace, newStackTrace(pcs, opts, callerOptions))
+	}
+	stackTrace := make(errors.StackTrace, len(pcs))
+	for i, pc := range pcs {
+		stackTrace[i] = errors.Frame(pc)
+	}
+	return context.WithValue(ctx, FieldStackTrace, fmt.Sprintf("%s%+v", err, stackTrace))
+}
+
+// stackTracers returns the first error implementing StackTracer in the chain of err, found with errors.As,
+// or one per branch when the chain contains multi-errors such as the ones returned by errors.Join.
+func stackTracers(err error) []StackTracer {
+	var st StackTracer
+	if !hasMultiError(err) {
+		if errors.As(err, &st) {
+			return []StackTracer{st}
+		}
+		return nil
+	}
+
+	// errors.As would only return the first match of the branches, the errors before the multi-error are checked
+	// one by one as errors.As does
+	for err != nil {
+		if x, ok := err.(StackTracer); ok {
+			return []StackTracer{x}
+		}
+		if x, ok := err.(interface{ As(any) bool }); ok && x.As(&st) {
+			return []StackTracer{st}
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() []error }:
+			var res []StackTracer
+			for _, e := range x.Unwrap() {
+				res = append(res, stackTracers(e)...)
+			}
+			return res
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func hasMultiError(err error) bool {
+	for err != nil {
+		switch x := err.(type) {
+		case interface{ Unwrap() []error }:
+			return true
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func formatStackTraces(tracers []StackTracer) string {
+	var stackTraces []string
+	for _, st := range tracers {
+		if _, ok := st.(fmt.Formatter); ok {
+			stackTraces = append(stackTraces, fmt.Sprintf("%+v", st))
+		} else if e, ok := st.(error); ok {
+			stackTraces = append(stackTraces, fmt.Sprintf("%s%+v", e, st.StackTrace()))
+		} else {
+			stackTraces = append(stackTraces, fmt.Sprintf("%+v", st.StackTrace()))
+		}
+	}
+	return strings.Join(stackTraces, "\n")
+}
+
+func GetStackTraceOptions() StackTraceOptions {
+	return global.GetStackTraceOptions()
+}
+
+func SetStackTraceOptions(opts StackTraceOptions) {
+	global.SetStackTraceOptions(opts)
+}
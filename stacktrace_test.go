@@ -0,0 +1,122 @@
+package log_test
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rockbears/log"
+)
+
+func TestContextWithStackTraceWrapped(t *testing.T) {
+	wrapped := fmt.Errorf("wrapped: %w", errors.WithStack(stderrors.New("this is an error")))
+	stackTrace, _ := log.ContextWithStackTrace(context.Background(), wrapped).Value(log.FieldStackTrace).(string)
+	if !strings.Contains(stackTrace, "this is an error") || !strings.Contains(stackTrace, "TestContextWithStackTraceWrapped") {
+		t.Fatalf("unexpected stack trace %q", stackTrace)
+	}
+
+	joined := stderrors.Join(errors.New("first error"), stderrors.New("no stack"), fmt.Errorf("third: %w", errors.New("third error")))
+	stackTrace, _ = log.ContextWithStackTrace(context.Background(), joined).Value(log.FieldStackTrace).(string)
+	if !strings.Contains(stackTrace, "first error") || !strings.Contains(stackTrace, "third error") || strings.Contains(stackTrace, "no stack") {
+		t.Fatalf("unexpected stack trace %q", stackTrace)
+	}
+
+	if v := log.ContextWithStackTrace(context.Background(), stderrors.New("no stack")).Value(log.FieldStackTrace); v != nil {
+		t.Fatalf("want no stack trace, got %q", v)
+	}
+}
+
+func TestErrorWithStackTraceCapture(t *testing.T) {
+	w := &callerWrapper{fields: map[string]interface{}{}}
+	logger := log.NewWithFactory(func() log.Wrapper { return w })
+
+	logger.ErrorWithStackTrace(context.Background(), stderrors.New("no stack"))
+	if v, has := w.fields[string(log.FieldStackTrace)]; has {
+		t.Fatalf("want no stack trace, got %q", v)
+	}
+
+	logger.SetStackTraceOptions(log.StackTraceOptions{CaptureIfMissing: true})
+	logger.ErrorWithStackTrace(context.Background(), stderrors.New("no stack"))
+	stackTrace, _ := w.fields[string(log.FieldStackTrace)].(string)
+	lines := strings.Split(stackTrace, "\n")
+	if len(lines) < 2 || lines[0] != "no stack" || !strings.HasSuffix(lines[1], "TestErrorWithStackTraceCapture") {
+		t.Fatalf("unexpected stack trace %q", stackTrace)
+	}
+}
+
+func newStackError() error {
+	return errors.New("this is an error")
+}
+
+func TestStructuredStackTrace(t *testing.T) {
+	w := &callerWrapper{fields: map[string]interface{}{}}
+	logger := log.NewWithFactory(func() log.Wrapper { return w })
+	logger.SetStackTraceOptions(log.StackTraceOptions{Structured: true, ExcludeRuntime: true, ExcludeTesting: true})
+
+	logger.ErrorWithStackTrace(context.Background(), fmt.Errorf("wrapped: %w", newStackError()))
+	stackTrace, ok := w.fields[string(log.FieldStackTrace)].(log.StackTrace)
+	if !ok || len(stackTrace) != 2 {
+		t.Fatalf("want 2 frames, got %#v", w.fields[string(log.FieldStackTrace)])
+	}
+	if stackTrace[0].Function != "github.com/rockbears/log_test.newStackError" || stackTrace[1].Function != "github.com/rockbears/log_test.TestStructuredStackTrace" {
+		t.Fatalf("unexpected frames %v", stackTrace)
+	}
+	if !strings.HasSuffix(stackTrace[0].File, "stacktrace_test.go") || stackTrace[0].Line != 51 {
+		t.Fatalf("unexpected frame %v", stackTrace[0])
+	}
+
+	logger.SetStackTraceOptions(log.StackTraceOptions{Structured: true, CaptureIfMissing: true, MaxDepth: 1})
+	logger.ErrorWithStackTrace(context.Background(), stderrors.New("no stack"))
+	stackTrace, _ = w.fields[string(log.FieldStackTrace)].(log.StackTrace)
+	if len(stackTrace) != 1 || stackTrace[0].Function != "github.com/rockbears/log_test.TestStructuredStackTrace" {
+		t.Fatalf("unexpected frames %v", stackTrace)
+	}
+}
+
+func ExampleStackTrace() {
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{DisableTimestamp: true}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.SetCallerOptions(log.CallerOptions{PathFormat: log.PathBase, FunctionFormat: log.FunctionPackage})
+	logger.SetStackTraceOptions(log.StackTraceOptions{Structured: true, ExcludeRuntime: true, ExcludeTesting: true, MaxDepth: 1})
+
+	logger.ErrorWithStackTrace(context.Background(), newStackError())
+	// Output:
+	// [ERROR] [stack_trace=
+	// 	log_test.newStackError
+	// 		stacktrace_test.go:51] this is an error
+}
+
+// asError carries no stack trace but provides one through its As method
+type asError struct {
+	stackTracer log.StackTracer
+}
+
+func (e asError) Error() string { return "as error" }
+
+func (e asError) As(target any) bool {
+	if p, ok := target.(*log.StackTracer); ok {
+		*p = e.stackTracer
+		return true
+	}
+	return false
+}
+
+func TestContextWithStackTraceAs(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", asError{stackTracer: errors.WithStack(stderrors.New("this is an error")).(log.StackTracer)})
+	stackTrace, _ := log.ContextWithStackTrace(context.Background(), err).Value(log.FieldStackTrace).(string)
+	if !strings.Contains(stackTrace, "this is an error") || !strings.Contains(stackTrace, "TestContextWithStackTraceAs") {
+		t.Fatalf("unexpected stack trace %q", stackTrace)
+	}
+}
+
+func TestContextWithStackTraceOptions(t *testing.T) {
+	logger := log.New()
+	logger.SetStackTraceOptions(log.StackTraceOptions{CaptureIfMissing: true, Structured: true})
+	stackTrace, _ := logger.ContextWithStackTrace(context.Background(), stderrors.New("no stack")).Value(log.FieldStackTrace).(log.StackTrace)
+	if len(stackTrace) == 0 || stackTrace[0].Function != "github.com/rockbears/log_test.TestContextWithStackTraceOptions" {
+		t.Fatalf("unexpected stack trace %v", stackTrace)
+	}
+}
@@ -0,0 +1,316 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Syslog wrapper */
+
+type SyslogFormat int
+
+const (
+	SyslogRFC5424 SyslogFormat = iota
+	SyslogRFC3164
+)
+
+type SyslogFacility int
+
+const (
+	SyslogFacilityKern SyslogFacility = iota
+	SyslogFacilityUser
+	SyslogFacilityMail
+	SyslogFacilityDaemon
+	SyslogFacilityAuth
+	SyslogFacilitySyslog
+	SyslogFacilityLpr
+	SyslogFacilityNews
+	SyslogFacilityUucp
+	SyslogFacilityCron
+	SyslogFacilityAuthPriv
+	SyslogFacilityFTP
+)
+
+const (
+	SyslogFacilityLocal0 SyslogFacility = iota + 16
+	SyslogFacilityLocal1
+	SyslogFacilityLocal2
+	SyslogFacilityLocal3
+	SyslogFacilityLocal4
+	SyslogFacilityLocal5
+	SyslogFacilityLocal6
+	SyslogFacilityLocal7
+)
+
+var syslogSeverities = map[Level]int{
+	LevelDebug: 7,
+	LevelInfo:  6,
+	LevelWarn:  4,
+	LevelError: 3,
+	LevelFatal: 2,
+	LevelPanic: 1,
+}
+
+type SyslogOptions struct {
+	// Network is udp, tcp or unixgram. Messages are framed with octet counting over tcp.
+	Network string
+	Address string
+	Format  SyslogFormat
+	// Facility defaults to SyslogFacilityUser when nil
+	Facility *SyslogFacility
+	// AppName defaults to the name of the executable, Hostname to the name of the host
+	AppName  string
+	Hostname string
+	Level    Level
+	// StructuredDataID is the ID of the RFC 5424 structured data element holding the fields, it defaults to fields@32473
+	StructuredDataID string
+	// DialTimeout and WriteTimeout default to 5 seconds
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	// OnError is called when a message cannot be sent, even after reconnecting
+	OnError func(err error)
+}
+
+// Syslog sends messages to a syslog daemon, reconnecting when sending fails. It is safe for concurrent use.
+type Syslog struct {
+	opts     SyslogOptions
+	facility SyslogFacility
+	pid      string
+	mutex    sync.Mutex
+	conn     net.Conn
+}
+
+func NewSyslog(opts SyslogOptions) (*Syslog, error) {
+	switch opts.Network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", opts.Network)
+	}
+	facility := SyslogFacilityUser
+	if opts.Facility != nil {
+		facility = *opts.Facility
+	}
+	if facility < SyslogFacilityKern || facility > SyslogFacilityLocal7 {
+		return nil, fmt.Errorf("invalid syslog facility %d", facility)
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.StructuredDataID == "" {
+		opts.StructuredDataID = "fields@32473"
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 5 * time.Second
+	}
+	s := &Syslog{opts: opts, facility: facility, pid: strconv.Itoa(os.Getpid())}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect must be called with the mutex held.
+func (s *Syslog) connect() error {
+	conn, err := net.DialTimeout(s.opts.Network, s.opts.Address, s.opts.DialTimeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *Syslog) isStream() bool {
+	return strings.HasPrefix(s.opts.Network, "tcp")
+}
+
+// send writes the message, reconnecting once if writing fails.
+func (s *Syslog) send(msg string) {
+	if s.isStream() {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				continue
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.opts.WriteTimeout))
+		if _, err = s.conn.Write([]byte(msg)); err == nil {
+			return
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if s.opts.OnError != nil {
+		s.opts.OnError(err)
+	}
+}
+
+func (s *Syslog) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *Syslog) format(level Level, t time.Time, fields []entryField, msg string) string {
+	pri := int(s.facility)*8 + syslogSeverities[level]
+	hostname := syslogHeaderValue(s.opts.Hostname, 255)
+
+	var sb strings.Builder
+	if s.opts.Format == SyslogRFC3164 {
+		fmt.Fprintf(&sb, "<%d>%s %s %s[%s]: ", pri, t.Format(time.Stamp), hostname, syslogTag(s.opts.AppName), s.pid)
+		for _, f := range fields {
+			fmt.Fprintf(&sb, "[%s=%s]", syslogParamName(string(f.key)), syslogParamValue(fmt.Sprintf("%v", f.value)))
+		}
+		if len(fields) > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(msg)
+		return sb.String()
+	}
+
+	fmt.Fprintf(&sb, "<%d>1 %s %s %s %s - ", pri, t.Format("2006-01-02T15:04:05.000000Z07:00"), hostname, syslogHeaderValue(s.opts.AppName, 48), s.pid)
+	if len(fields) == 0 {
+		sb.WriteByte('-')
+	} else {
+		sb.WriteString("[" + s.opts.StructuredDataID)
+		for _, f := range fields {
+			fmt.Fprintf(&sb, " %s=\"%s\"", syslogParamName(string(f.key)), syslogParamValue(fmt.Sprintf("%v", f.value)))
+		}
+		sb.WriteByte(']')
+	}
+	sb.WriteString(" " + msg)
+	return sb.String()
+}
+
+// syslogHeaderValue keeps the printable US-ASCII characters allowed in the header fields of RFC 5424.
+func syslogHeaderValue(s string, maxLength int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > maxLength {
+		s = s[:maxLength]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// syslogTag keeps the characters of the RFC 3164 TAG which do not end it, at most 32.
+func syslogTag(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '[' || r == ']' || r == ':' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func syslogParamName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	return s
+}
+
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+func NewSyslogWrapper(s *Syslog) WrapperFactoryFunc {
+	return func() Wrapper {
+		return &SyslogWrapper{syslog: s}
+	}
+}
+
+type SyslogWrapper struct {
+	syslog *Syslog
+	fields []entryField
+	time   time.Time
+}
+
+func (l *SyslogWrapper) GetLevel() Level {
+	return l.syslog.opts.Level
+}
+
+func (l *SyslogWrapper) WithField(key string, value interface{}) {
+	l.fields = append(l.fields, entryField{key: Field(key), value: value})
+}
+
+func (l *SyslogWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *SyslogWrapper) send(level Level, format string, args ...interface{}) {
+	t := l.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	l.syslog.send(l.syslog.format(level, t, l.fields, getFormatedMsg(format, args...)))
+}
+
+func (l *SyslogWrapper) Debugf(format string, args ...interface{}) {
+	l.send(LevelDebug, format, args...)
+}
+
+func (l *SyslogWrapper) Infof(format string, args ...interface{}) {
+	l.send(LevelInfo, format, args...)
+}
+
+func (l *SyslogWrapper) Warnf(format string, args ...interface{}) {
+	l.send(LevelWarn, format, args...)
+}
+
+func (l *SyslogWrapper) Fatalf(format string, args ...interface{}) {
+	l.send(LevelFatal, format, args...)
+	os.Exit(1)
+}
+
+func (l *SyslogWrapper) Errorf(format string, args ...interface{}) {
+	l.send(LevelError, format, args...)
+}
+
+func (l *SyslogWrapper) Panicf(format string, args ...interface{}) {
+	l.send(LevelPanic, format, args...)
+	panic(getFormatedMsg(format, args...))
+}
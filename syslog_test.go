@@ -0,0 +1,173 @@
+package log_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+func newSyslogLogger(t *testing.T, opts log.SyslogOptions) *log.Logger {
+	t.Helper()
+	if opts.AppName == "" {
+		opts.AppName = "myapp"
+	}
+	if opts.Hostname == "" {
+		opts.Hostname = "myhost"
+	}
+	s, err := log.NewSyslog(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	logger := log.NewWithFactory(log.NewSyslogWrapper(s))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+	logger.RegisterField(fieldComponent)
+	logger.SetClock(func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) })
+	return logger
+}
+
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	facility := log.SyslogFacilityLocal0
+	logger := newSyslogLogger(t, log.SyslogOptions{Network: "udp", Address: conn.LocalAddr().String(), Facility: &facility})
+	ctx := context.WithValue(context.Background(), fieldComponent, `a "quoted" value]`)
+	logger.Warn(ctx, "this is warn")
+	logger.Info(context.Background(), "this is info")
+
+	want := regexp.MustCompile(`^<132>1 2024-05-01T12:00:00\.000000Z myhost myapp \d+ - \[fields@32473 component="a \\"quoted\\" value\\]"\] this is warn$`)
+	if got := readPacket(t, conn); !want.MatchString(got) {
+		t.Fatalf("unexpected message %q", got)
+	}
+	want = regexp.MustCompile(`^<134>1 2024-05-01T12:00:00\.000000Z myhost myapp \d+ - - this is info$`)
+	if got := readPacket(t, conn); !want.MatchString(got) {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestSyslogKernFacilityAndHostname(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	hostname := "my host\n" + strings.Repeat("h", 300)
+	facility := log.SyslogFacilityKern
+	logger := newSyslogLogger(t, log.SyslogOptions{Network: "udp", Address: conn.LocalAddr().String(), Facility: &facility, Hostname: hostname})
+	logger.Error(context.Background(), "this is error")
+
+	want := regexp.MustCompile(`^<3>1 2024-05-01T12:00:00\.000000Z my_host_h{247} myapp \d+ - - this is error$`)
+	if got := readPacket(t, conn); !want.MatchString(got) {
+		t.Fatalf("unexpected message %q", got)
+	}
+
+	for _, invalid := range []log.SyslogFacility{-1, 24} {
+		if _, err := log.NewSyslog(log.SyslogOptions{Network: "udp", Address: conn.LocalAddr().String(), Facility: &invalid}); err == nil {
+			t.Fatalf("want error for facility %d", invalid)
+		}
+	}
+}
+
+func TestSyslogUnixgramRFC3164(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	logger := newSyslogLogger(t, log.SyslogOptions{Network: "unixgram", Address: path, Format: log.SyslogRFC3164, AppName: "my app:[x]"})
+	ctx := context.WithValue(context.Background(), fieldComponent, "a]b")
+	logger.Error(ctx, "this is error")
+
+	want := regexp.MustCompile(`^<11>May  1 12:00:00 myhost my_app__x_\[\d+\]: \[component=a\\\]b\] this is error$`)
+	if got := readPacket(t, conn); !want.MatchString(got) {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	logger := newSyslogLogger(t, log.SyslogOptions{Network: "tcp", Address: ln.Addr().String()})
+	logger.Info(context.Background(), "first message")
+	first := <-conns
+	if got := readFrame(t, bufio.NewReader(first)); !strings.HasSuffix(got, " - - first message") {
+		t.Fatalf("unexpected message %q", got)
+	}
+	first.Close()
+
+	// Writes to the closed connection fail after a while, the logger reconnects then
+	deadline := time.After(5 * time.Second)
+	for {
+		logger.Info(context.Background(), "second message")
+		select {
+		case second := <-conns:
+			defer second.Close()
+			_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if got := readFrame(t, bufio.NewReader(second)); !strings.HasSuffix(got, " - - second message") {
+				t.Fatalf("unexpected message %q", got)
+			}
+			return
+		case <-deadline:
+			t.Fatal("the logger did not reconnect")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package log_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rockbears/log"
+)
+
+type fakeTB struct {
+	testing.TB
+	logs     []string
+	errors   []string
+	cleanups []func()
+	failed   bool
+}
+
+func (f *fakeTB) Name() string { return "TestFake" }
+func (f *fakeTB) Log(args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+func (f *fakeTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+func (f *fakeTB) Error(args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprint(args...))
+	f.failed = true
+}
+func (f *fakeTB) Fail()        { f.failed = true }
+func (f *fakeTB) Failed() bool { return f.failed }
+func (f *fakeTB) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+func (f *fakeTB) complete() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestTestingWrapperOptions(t *testing.T) {
+	tb := &fakeTB{TB: t}
+	logger := log.NewWithFactory(log.NewTestingWrapperWithOptions(tb, log.TestingWrapperOptions{Level: log.LevelInfo, FailOnError: true}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+
+	logger.Debug(context.Background(), "this is debug")
+	logger.Info(context.Background(), "this is info")
+	logger.Error(context.Background(), "this is error")
+
+	if len(tb.logs) != 1 || tb.logs[0] != "[INFO]  this is info" {
+		t.Fatalf("unexpected logs %v", tb.logs)
+	}
+	if len(tb.errors) != 1 || tb.errors[0] != "[ERROR]  this is error" {
+		t.Fatalf("unexpected errors %v", tb.errors)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "this is panic" {
+				t.Fatalf("want a panic, got %v", r)
+			}
+		}()
+		logger.Panic(context.Background(), "this is panic")
+	}()
+
+	tb.complete()
+	logger.Info(context.Background(), "this is late")
+	if len(tb.logs) != 1 {
+		t.Fatalf("late logs should not reach the test, got %v", tb.logs)
+	}
+}
+
+func TestTestingWrapperOnlyOnFailure(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		tb := &fakeTB{TB: t}
+		logger := log.NewWithFactory(log.NewTestingWrapperWithOptions(tb, log.TestingWrapperOptions{OnlyOnFailure: true, MaxBufferedEntries: 2}))
+		logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+		logger.RegisterField(fieldComponent)
+
+		ctx := context.WithValue(context.Background(), fieldComponent, "api")
+		logger.Info(ctx, "first")
+		logger.Info(ctx, "second")
+		logger.Warn(ctx, "third")
+		if len(tb.logs) != 0 {
+			t.Fatalf("entries should be held, got %v", tb.logs)
+		}
+		tb.failed = failed
+		tb.complete()
+
+		if !failed {
+			if len(tb.logs) != 0 {
+				t.Fatalf("entries should be discarded when the test succeeds, got %v", tb.logs)
+			}
+			continue
+		}
+		if len(tb.logs) != 3 || tb.logs[0] != "1 log entries dropped because the buffer was full" {
+			t.Fatalf("unexpected logs %v", tb.logs)
+		}
+		if !strings.HasSuffix(tb.logs[1], " [INFO] [component=api] second") || !strings.HasSuffix(tb.logs[2], " [WARN] [component=api] third") {
+			t.Fatalf("unexpected logs %v", tb.logs)
+		}
+		if _, err := time.Parse("15:04:05.000000", strings.Fields(tb.logs[1])[0]); err != nil {
+			t.Fatalf("entries should start with their time: %v", err)
+		}
+	}
+}
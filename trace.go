@@ -0,0 +1,153 @@
+package log
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	FieldTraceID    = Field("trace_id")
+	FieldSpanID     = Field("span_id")
+	FieldTraceFlags = Field("trace_flags")
+)
+
+const (
+	contextKeyTraceParent = contextKey("traceparent")
+	contextKeySpanContext = contextKey("span_context")
+)
+
+// TraceParent is a W3C trace context, as carried by the traceparent HTTP header.
+type TraceParent struct {
+	TraceID string
+	SpanID  string
+	Flags   byte
+}
+
+func ParseTraceParent(s string) (TraceParent, error) {
+	var tp TraceParent
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return tp, fmt.Errorf("invalid traceparent %q", s)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// Future versions may append fields, version 00 must not
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return tp, fmt.Errorf("invalid traceparent version in %q", s)
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return tp, fmt.Errorf("invalid trace id in %q", s)
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return tp, fmt.Errorf("invalid span id in %q", s)
+	}
+	if !isLowerHex(flags, 2) {
+		return tp, fmt.Errorf("invalid trace flags in %q", s)
+	}
+	f, _ := strconv.ParseUint(flags, 16, 8)
+	return TraceParent{TraceID: traceID, SpanID: spanID, Flags: byte(f)}, nil
+}
+
+func (tp TraceParent) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", tp.TraceID, tp.SpanID, tp.Flags)
+}
+
+func (tp TraceParent) Sampled() bool {
+	return tp.Flags&0x01 == 0x01
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func ContextWithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, contextKeyTraceParent, tp)
+}
+
+func TraceParentFromContext(ctx context.Context) (TraceParent, bool) {
+	tp, ok := ctx.Value(contextKeyTraceParent).(TraceParent)
+	return tp, ok
+}
+
+// SpanContexter is implemented by the spans of tracing libraries, or by adapters around them.
+type SpanContexter interface {
+	SpanContext() (traceID, spanID string)
+}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContexter) context.Context {
+	return context.WithValue(ctx, contextKeySpanContext, sc)
+}
+
+type TraceContext struct {
+	TraceID    string
+	SpanID     string
+	TraceFlags string
+}
+
+// TraceExtractor returns the trace context carried by ctx, if any.
+type TraceExtractor func(ctx context.Context) (TraceContext, bool)
+
+func TraceParentExtractor(ctx context.Context) (TraceContext, bool) {
+	tp, ok := TraceParentFromContext(ctx)
+	if !ok {
+		return TraceContext{}, false
+	}
+	return TraceContext{TraceID: tp.TraceID, SpanID: tp.SpanID, TraceFlags: fmt.Sprintf("%02x", tp.Flags)}, true
+}
+
+func SpanContextExtractor(ctx context.Context) (TraceContext, bool) {
+	sc, ok := ctx.Value(contextKeySpanContext).(SpanContexter)
+	if !ok {
+		return TraceContext{}, false
+	}
+	traceID, spanID := sc.SpanContext()
+	if traceID == "" {
+		return TraceContext{}, false
+	}
+	return TraceContext{TraceID: traceID, SpanID: spanID}, true
+}
+
+func DefaultTraceExtractors() []TraceExtractor {
+	return []TraceExtractor{TraceParentExtractor, SpanContextExtractor}
+}
+
+// SetTraceExtractors replaces the trace extractors of the logger. Trace extraction is disabled when none is given.
+func (l *Logger) SetTraceExtractors(extractors ...TraceExtractor) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.traceExtractors = extractors
+}
+
+// contextWithTrace adds the first trace context found by the extractors to the context, unless FieldTraceID is already set.
+// The trace fields are emitted even if they are not registered and exclude rules apply to them.
+func contextWithTrace(ctx context.Context, extractors []TraceExtractor) context.Context {
+	if len(extractors) == 0 || ctx.Value(FieldTraceID) != nil {
+		return ctx
+	}
+	for _, extract := range extractors {
+		tc, ok := extract(ctx)
+		if !ok {
+			continue
+		}
+		values := map[Field]interface{}{FieldTraceID: tc.TraceID}
+		if tc.SpanID != "" {
+			values[FieldSpanID] = tc.SpanID
+		}
+		if tc.TraceFlags != "" {
+			values[FieldTraceFlags] = tc.TraceFlags
+		}
+		return contextWithFields(ctx, values)
+	}
+	return ctx
+}
+
+func SetTraceExtractors(extractors ...TraceExtractor) {
+	global.SetTraceExtractors(extractors...)
+}
@@ -0,0 +1,72 @@
+package log_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rockbears/log"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tp, err := log.ParseTraceParent(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tp.SpanID != "00f067aa0ba902b7" || !tp.Sampled() {
+		t.Fatalf("unexpected traceparent %+v", tp)
+	}
+	if tp.String() != valid {
+		t.Fatalf("want %q, got %q", valid, tp.String())
+	}
+
+	if _, err := log.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future"); err != nil {
+		t.Fatalf("future versions may append fields: %v", err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		if _, err := log.ParseTraceParent(invalid); err == nil {
+			t.Errorf("want error for %q", invalid)
+		}
+	}
+}
+
+type span struct {
+	traceID, spanID string
+}
+
+func (s span) SpanContext() (string, string) {
+	return s.traceID, s.spanID
+}
+
+func ExampleContextWithTraceParent() {
+	logger := log.NewWithFactory(log.NewStdWrapper(log.StdWrapperOptions{DisableTimestamp: true}))
+	logger.UnregisterField(log.FieldSourceFile, log.FieldSourceLine, log.FieldCaller)
+
+	tp, _ := log.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := log.ContextWithTraceParent(context.Background(), tp)
+	logger.Info(ctx, "from traceparent")
+
+	ctx = log.ContextWithSpanContext(context.Background(), span{traceID: "0af7651916cd43dd8448eb211c80319c", spanID: "b7ad6b7169203331"})
+	logger.Info(ctx, "from span context")
+
+	logger.Skip(log.FieldTraceID, "0af7651916cd43dd8448eb211c80319c")
+	logger.Info(ctx, "this log should not be displayed because is should be skipped")
+
+	logger.SetTraceExtractors()
+	logger.Info(ctx, "without trace extraction")
+	// Output:
+	// [INFO] [span_id=00f067aa0ba902b7][trace_flags=01][trace_id=4bf92f3577b34da6a3ce929d0e0e4736] from traceparent
+	// [INFO] [span_id=b7ad6b7169203331][trace_id=0af7651916cd43dd8448eb211c80319c] from span context
+	// [INFO]  without trace extraction
+}
@@ -1,13 +1,18 @@
 package log
 
+import (
+	"fmt"
+	"strings"
+)
+
 type (
 	Field string
 	Level int
 )
 
 type ExcludeRule struct {
-	Field Field
-	Value any
+	Field Field `json:"field"`
+	Value any   `json:"value"`
 }
 
 const (
@@ -19,6 +24,51 @@ const (
 	LevelPanic
 )
 
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+	LevelFatal: "fatal",
+	LevelPanic: "panic",
+}
+
+func (l Level) String() string {
+	if s, ok := levelNames[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "warning" {
+		return LevelWarn, nil
+	}
+	for l, name := range levelNames {
+		if name == s {
+			return l, nil
+		}
+	}
+	return LevelDebug, fmt.Errorf("unknown log level %q", s)
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	if _, ok := levelNames[l]; !ok {
+		return nil, fmt.Errorf("unknown log level %d", int(l))
+	}
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
 type Wrapper interface {
 	GetLevel() Level
 	WithField(key string, value interface{})
@@ -2,13 +2,19 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 /* Zap wrapper */
@@ -22,6 +28,7 @@ func NewZapWrapper(logger *zap.Logger) WrapperFactoryFunc {
 type ZapWrapper struct {
 	logger *zap.Logger
 	sugar  *zap.SugaredLogger
+	time   time.Time
 }
 
 func (l *ZapWrapper) GetLevel() Level {
@@ -47,6 +54,21 @@ func (l *ZapWrapper) WithField(key string, value interface{}) {
 	l.sugar = l.sugar.With(key, value)
 }
 
+func (l *ZapWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
+func (l *ZapWrapper) write(level zapcore.Level, msg string) {
+	if l.time.IsZero() {
+		l.sugar.Logw(level, msg)
+		return
+	}
+	if ce := l.sugar.Desugar().Check(level, msg); ce != nil {
+		ce.Time = l.time
+		ce.Write()
+	}
+}
+
 func (l *ZapWrapper) format(format string, args ...interface{}) string {
 	msg := format
 	if len(args) > 0 {
@@ -56,27 +78,27 @@ func (l *ZapWrapper) format(format string, args ...interface{}) string {
 }
 
 func (l *ZapWrapper) Debugf(format string, args ...interface{}) {
-	l.sugar.Debugw(l.format(format, args...))
+	l.write(zap.DebugLevel, l.format(format, args...))
 }
 
 func (l *ZapWrapper) Infof(format string, args ...interface{}) {
-	l.sugar.Infow(l.format(format, args...))
+	l.write(zap.InfoLevel, l.format(format, args...))
 }
 
 func (l *ZapWrapper) Warnf(format string, args ...interface{}) {
-	l.sugar.Warnw(l.format(format, args...))
+	l.write(zap.WarnLevel, l.format(format, args...))
 }
 
 func (l *ZapWrapper) Fatalf(format string, args ...interface{}) {
-	l.sugar.Fatalw(l.format(format, args...))
+	l.write(zap.FatalLevel, l.format(format, args...))
 }
 
 func (l *ZapWrapper) Errorf(format string, args ...interface{}) {
-	l.sugar.Errorw(l.format(format, args...))
+	l.write(zap.ErrorLevel, l.format(format, args...))
 }
 
 func (l *ZapWrapper) Panicf(format string, args ...interface{}) {
-	l.sugar.Panicw(l.format(format, args...))
+	l.write(zap.PanicLevel, l.format(format, args...))
 }
 
 /* Logrus wrapper */
@@ -114,6 +136,10 @@ func (l *LogrusWrapper) WithField(key string, value interface{}) {
 	l.entry = l.entry.WithField(key, value)
 }
 
+func (l *LogrusWrapper) WithTime(t time.Time) {
+	l.entry = l.entry.WithTime(t)
+}
+
 func (l *LogrusWrapper) Debugf(format string, args ...interface{}) {
 	if len(args) == 0 {
 		l.entry.Debug(format)
@@ -164,19 +190,74 @@ func (l *LogrusWrapper) Panicf(format string, args ...interface{}) {
 
 /* testing.T wrapper */
 
+type TestingWrapperOptions struct {
+	// Level filters the entries below it
+	Level Level
+	// FailOnError marks the test as failed when an entry at LevelError or above is logged
+	FailOnError bool
+	// OnlyOnFailure holds the entries in memory and writes them, with their time, only if the test fails
+	OnlyOnFailure bool
+	// MaxBufferedEntries defaults to 10000, the oldest entries are dropped when it is reached
+	MaxBufferedEntries int
+	// FlushOnVerbose writes the entries immediately when the tests run with -v, even with OnlyOnFailure
+	FlushOnVerbose bool
+}
+
 func NewTestingWrapper(t testing.TB) WrapperFactoryFunc {
+	return NewTestingWrapperWithOptions(t, TestingWrapperOptions{})
+}
+
+// NewTestingWrapperWithOptions returns a factory writing to t.Log. Entries logged once the test has completed
+// are written to stderr with the name of the test instead.
+func NewTestingWrapperWithOptions(t testing.TB, opts TestingWrapperOptions) WrapperFactoryFunc {
+	if opts.MaxBufferedEntries <= 0 {
+		opts.MaxBufferedEntries = 10000
+	}
+	state := &testingState{name: t.Name(), buffered: opts.OnlyOnFailure && !(opts.FlushOnVerbose && testing.Verbose())}
+	t.Cleanup(func() {
+		state.mutex.Lock()
+		state.done.Store(true)
+		lines, dropped := state.lines, state.dropped
+		state.lines = nil
+		state.mutex.Unlock()
+
+		if t.Failed() {
+			if dropped > 0 {
+				t.Logf("%d log entries dropped because the buffer was full", dropped)
+			}
+			for _, line := range lines {
+				t.Log(line)
+			}
+		}
+	})
 	return func() Wrapper {
-		return &TestingWrapper{t: t}
+		return &TestingWrapper{t: t, opts: opts, state: state}
 	}
 }
 
+type testingState struct {
+	name     string
+	done     atomic.Bool
+	buffered bool
+	mutex    sync.Mutex
+	lines    []string
+	dropped  int
+}
+
 type TestingWrapper struct {
-	ctx map[string]string
-	t   testing.TB
+	ctx   map[string]string
+	t     testing.TB
+	opts  TestingWrapperOptions
+	state *testingState
+	time  time.Time
+}
+
+func (l *TestingWrapper) WithTime(t time.Time) {
+	l.time = t
 }
 
 func (l *TestingWrapper) GetLevel() Level {
-	return LevelDebug
+	return l.opts.Level
 }
 
 func (l *TestingWrapper) WithField(key string, value interface{}) {
@@ -216,48 +297,91 @@ func getFormatedMsg(format string, args ...interface{}) string {
 	return formatedMsg
 }
 
-func (l *TestingWrapper) log(level, format string, args ...interface{}) {
-	// Recover function to avoid panic: Log in goroutine after test has completed
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Print("[" + level + "] " + formatCtx(l.ctx) + " " + getFormatedMsg(format, args...))
-		}
-	}()
-	l.t.Log("[" + level + "] " + formatCtx(l.ctx) + " " + getFormatedMsg(format, args...))
+func (l *TestingWrapper) lateLog(line string) {
+	fmt.Fprintf(os.Stderr, "log after %s has completed: %s\n", l.state.name, line)
 }
 
-func (l *TestingWrapper) fatal(format string, args ...interface{}) {
+// write returns false if the test has completed, the line is written to stderr then.
+func (l *TestingWrapper) write(line string, fail bool) (written bool) {
+	if l.state.buffered {
+		return l.hold(line, fail)
+	}
+	if l.state.done.Load() {
+		l.lateLog(line)
+		return false
+	}
+	// The test may complete concurrently, testing panics when logging afterwards
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Print("[FATAL] " + formatCtx(l.ctx) + " " + getFormatedMsg(format, args...))
-			os.Exit(2)
+			l.lateLog(line)
+			written = false
 		}
 	}()
-	l.t.Fatal("[FATAL] " + formatCtx(l.ctx) + " " + getFormatedMsg(format, args...))
+	if fail {
+		l.t.Error(line)
+	} else {
+		l.t.Log(line)
+	}
+	return true
+}
+
+func (l *TestingWrapper) hold(line string, fail bool) bool {
+	t := l.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	line = t.Format("15:04:05.000000") + " " + line
+
+	l.state.mutex.Lock()
+	if l.state.done.Load() {
+		l.state.mutex.Unlock()
+		l.lateLog(line)
+		return false
+	}
+	l.state.lines = append(l.state.lines, line)
+	if len(l.state.lines) > l.opts.MaxBufferedEntries {
+		l.state.lines = l.state.lines[1:]
+		l.state.dropped++
+	}
+	l.state.mutex.Unlock()
+
+	if fail {
+		l.t.Fail()
+	}
+	return true
+}
+
+func (l *TestingWrapper) line(level, format string, args ...interface{}) string {
+	return "[" + level + "] " + formatCtx(l.ctx) + " " + getFormatedMsg(format, args...)
 }
 
 func (l *TestingWrapper) Debugf(format string, args ...interface{}) {
-	l.log("DEBUG", format, args...)
+	l.write(l.line("DEBUG", format, args...), false)
 }
 
 func (l *TestingWrapper) Infof(format string, args ...interface{}) {
-	l.log("INFO", format, args...)
+	l.write(l.line("INFO", format, args...), false)
 }
 
 func (l *TestingWrapper) Warnf(format string, args ...interface{}) {
-	l.log("WARN", format, args...)
+	l.write(l.line("WARN", format, args...), false)
 }
 
 func (l *TestingWrapper) Fatalf(format string, args ...interface{}) {
-	l.fatal(format, args...)
+	line := l.line("FATAL", format, args...)
+	if !l.write(line, true) {
+		os.Exit(2)
+	}
+	l.t.FailNow()
 }
 
 func (l *TestingWrapper) Errorf(format string, args ...interface{}) {
-	l.log("ERROR", format, args...)
+	l.write(l.line("ERROR", format, args...), l.opts.FailOnError)
 }
 
 func (l *TestingWrapper) Panicf(format string, args ...interface{}) {
-	l.log("PANIC", format, args...)
+	l.write(l.line("PANIC", format, args...), l.opts.FailOnError)
+	panic(getFormatedMsg(format, args...))
 }
 
 /* golang log package wrapper */
@@ -265,11 +389,14 @@ func (l *TestingWrapper) Panicf(format string, args ...interface{}) {
 type StdWrapperOptions struct {
 	Level            Level
 	DisableTimestamp bool
+	// Output, such as a RotatingFile, replaces the output of the log package, or stdout when DisableTimestamp is set
+	Output io.Writer
 }
 
 type StdWrapper struct {
 	opts StdWrapperOptions
 	ctx  map[string]string
+	time time.Time
 }
 
 func NewStdWrapper(opts StdWrapperOptions) WrapperFactoryFunc {
@@ -289,14 +416,55 @@ func (l *StdWrapper) WithField(key string, value interface{}) {
 	l.ctx[key] = fmt.Sprintf("%v", value)
 }
 
+func (l *StdWrapper) WithTime(t time.Time) {
+	l.time = t
+}
+
 func (l *StdWrapper) Print(s string) {
-	if l.opts.DisableTimestamp {
+	switch {
+	case l.opts.Output != nil:
+		if !l.opts.DisableTimestamp {
+			t := l.time
+			if t.IsZero() {
+				t = time.Now()
+			}
+			s = t.Format("2006/01/02 15:04:05") + " " + s
+		}
+		// A single write, the writers safe for concurrent use do not interleave the lines
+		_, _ = io.WriteString(l.opts.Output, s+"\n")
+	case l.opts.DisableTimestamp:
 		fmt.Println(s)
-	} else {
+	case !l.time.IsZero():
+		fmt.Fprint(log.Writer(), stdLogHeader(l.time, log.Flags(), log.Prefix())+s+"\n")
+	default:
 		log.Println(s)
 	}
 }
 
+// stdLogHeader formats the header of the standard logger for t, as log.Println would for the current time.
+// The file flags are ignored, the file would be the one of the wrapper.
+func stdLogHeader(t time.Time, flags int, prefix string) string {
+	var sb strings.Builder
+	if flags&log.Lmsgprefix == 0 {
+		sb.WriteString(prefix)
+	}
+	if flags&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	if flags&log.Ldate != 0 {
+		sb.WriteString(t.Format("2006/01/02 "))
+	}
+	if flags&log.Lmicroseconds != 0 {
+		sb.WriteString(t.Format("15:04:05.000000 "))
+	} else if flags&log.Ltime != 0 {
+		sb.WriteString(t.Format("15:04:05 "))
+	}
+	if flags&log.Lmsgprefix != 0 {
+		sb.WriteString(prefix)
+	}
+	return sb.String()
+}
+
 func (l *StdWrapper) Debugf(format string, args ...interface{}) {
 	l.Print("[DEBUG] " + formatCtx(l.ctx) + " " + getFormatedMsg(format, args...))
 }